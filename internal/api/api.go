@@ -2,33 +2,59 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/jbpratt/streammanager/internal/logbuffer"
 	"github.com/jbpratt/streammanager/internal/streammanager"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 type Server struct {
-	sm        *streammanager.StreamManager
-	logger    *zap.Logger
-	rtmpAddr  string
-	webrtcSrv WebRTCStatusProvider
-	fileDir   string           // Directory to serve files from
-	logLevel  *zap.AtomicLevel // Atomic log level for runtime changes
+	sm         *streammanager.StreamManager
+	logger     *zap.Logger
+	rtmpAddr   string
+	webrtcSrv  WebRTCStatusProvider
+	fileDir    string           // Directory to serve files from
+	sourceDirs []string         // Directories absolute enqueue paths must be within, empty allows any
+	logLevel   *zap.AtomicLevel // Atomic log level for runtime changes
+	integrity  string           // Integrity check mode applied to enqueued files
+	logBuffer  *logbuffer.Buffer
+	adminToken string // Bearer token required by admin endpoints, empty disables them
+	shutdown   func() // Starts the process's graceful shutdown
+
+	restreamer     WebRTCRestreamer
+	restreamMu     sync.Mutex
+	restreamDest   string
+	restreamCancel context.CancelFunc
+
+	closing   chan struct{} // Closed to end open event streams on shutdown
+	closeOnce sync.Once
 }
 
 type WebRTCStatusProvider interface {
 	GetStatus() map[string]any
 }
 
+// WebRTCRestreamer forwards a WHIP broadcast out to an RTMP destination
+type WebRTCRestreamer interface {
+	OnBroadcastStart(fn func())
+	Restream(ctx context.Context, destination string) error
+}
+
 func New(logger *zap.Logger, rtmpAddr string, logLevel *zap.AtomicLevel, fifoPath string) (*Server, error) {
 	sm, err := streammanager.New(logger, fifoPath)
 	if err != nil {
@@ -47,11 +73,70 @@ func New(logger *zap.Logger, rtmpAddr string, logLevel *zap.AtomicLevel, fifoPat
 		rtmpAddr: rtmpAddr,
 		fileDir:  fileDir,
 		logLevel: logLevel,
+		closing:  make(chan struct{}),
 	}, nil
 }
 
 func (s *Server) SetWebRTCServer(webrtcSrv WebRTCStatusProvider) {
 	s.webrtcSrv = webrtcSrv
+
+	if restreamer, ok := webrtcSrv.(WebRTCRestreamer); ok {
+		s.restreamer = restreamer
+		restreamer.OnBroadcastStart(s.startRestream)
+	}
+}
+
+// startRestream begins forwarding the WHIP broadcast if a restream
+// destination is configured and no restream is already running
+func (s *Server) startRestream() {
+	s.restreamMu.Lock()
+	defer s.restreamMu.Unlock()
+
+	if s.restreamer == nil || s.restreamDest == "" || s.restreamCancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.restreamCancel = cancel
+	dest := s.restreamDest
+
+	go func() {
+		err := s.restreamer.Restream(ctx, dest)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Error("WHIP restream stopped", zap.String("destination", dest), zap.Error(err))
+		} else {
+			s.logger.Info("WHIP restream stopped", zap.String("destination", dest))
+		}
+
+		s.restreamMu.Lock()
+		cancel()
+		s.restreamCancel = nil
+		s.restreamMu.Unlock()
+	}()
+}
+
+// SetAdminToken sets the bearer token required by admin endpoints such as
+// /shutdown. Admin endpoints are disabled while no token is set.
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// SetShutdownFunc sets the function /shutdown calls to begin a graceful shutdown
+func (s *Server) SetShutdownFunc(fn func()) {
+	s.shutdown = fn
+}
+
+// CloseStreams ends open event streams, which would otherwise hold up the
+// HTTP server's graceful shutdown until it times out
+func (s *Server) CloseStreams() {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+}
+
+// SetLogBuffer sets the buffer of recent application logs served by /logs/app
+func (s *Server) SetLogBuffer(buf *logbuffer.Buffer) {
+	s.logBuffer = buf
 }
 
 func (s *Server) SetFileDirectory(dir string) error {
@@ -69,6 +154,104 @@ func (s *Server) SetFileDirectory(dir string) error {
 	return nil
 }
 
+// SetAllowedSourceDirs restricts enqueued files to the file directory and the
+// given directories. With no directories any readable path may be enqueued.
+func (s *Server) SetAllowedSourceDirs(dirs []string) error {
+	var absDirs []string
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("invalid directory path: %w", err)
+		}
+		absDirs = append(absDirs, absDir)
+	}
+
+	s.sourceDirs = absDirs
+	if len(absDirs) > 0 {
+		s.logger.Info("Enqueue restricted to source directories", zap.Strings("directories", absDirs))
+	}
+	return nil
+}
+
+// resolveSourcePath returns path as an absolute path, resolving a relative
+// one against the file directory
+func (s *Server) resolveSourcePath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.fileDir, path)
+	}
+	return filepath.Abs(path)
+}
+
+// resolveSourcePaths resolves each non-empty path in place with
+// resolveSourcePath, reporting the first that fails
+func (s *Server) resolveSourcePaths(paths ...*string) error {
+	for _, path := range paths {
+		if path == nil || *path == "" {
+			continue
+		}
+		resolved, err := s.resolveSourcePath(*path)
+		if err != nil {
+			return fmt.Errorf("unable to resolve %s: %w", *path, err)
+		}
+		*path = resolved
+	}
+	return nil
+}
+
+// isAllowedSource reports whether the absolute file path may be enqueued
+func (s *Server) isAllowedSource(file string) bool {
+	if len(s.sourceDirs) == 0 {
+		return true
+	}
+	for _, dir := range append([]string{s.fileDir}, s.sourceDirs...) {
+		if isWithinDir(dir, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedRecordPath resolves path to an absolute path and reports whether a
+// recording may be written there. Unlike sources, recordings are always
+// confined to the file directory and the allowed source directories.
+func (s *Server) allowedRecordPath(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	for _, dir := range append([]string{s.fileDir}, s.sourceDirs...) {
+		if isWithinDir(dir, abs) {
+			return abs, true
+		}
+	}
+	return "", false
+}
+
+// isWithinDir reports whether path is dir or inside it
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// SetIntegrityCheck sets how enqueued files are checked for being incomplete
+// (off, size, or decode)
+func (s *Server) SetIntegrityCheck(mode string) error {
+	switch mode {
+	case streammanager.IntegrityCheckOff, streammanager.IntegrityCheckSize, streammanager.IntegrityCheckDecode:
+	default:
+		return fmt.Errorf("invalid integrity check mode: %s", mode)
+	}
+
+	s.integrity = mode
+	return nil
+}
+
 func (s *Server) StreamManager() *streammanager.StreamManager {
 	return s.sm
 }
@@ -97,6 +280,44 @@ func (s *Server) logMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// adminMiddleware rejects requests that do not carry the admin bearer token
+func (s *Server) adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			http.Error(w, "Admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			s.logger.Warn("Unauthorized admin request",
+				zap.String("path", r.URL.Path),
+				zap.String("remote_addr", r.RemoteAddr))
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// writeJSON encodes v as the JSON response body
+func (s *Server) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// writeOK writes a JSON success response carrying a human readable message
+func (s *Server) writeOK(w http.ResponseWriter, message string) {
+	s.writeJSON(w, map[string]string{
+		"status":  "ok",
+		"message": message,
+	})
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -107,18 +328,40 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer to flush
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (s *Server) SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/start", s.logMiddleware(s.handleStart))
 	mux.HandleFunc("/enqueue", s.logMiddleware(s.handleEnqueue))
 	mux.HandleFunc("/queue", s.logMiddleware(s.handleQueue))
+	mux.HandleFunc("/queue/", s.logMiddleware(s.handleQueueEntry))
 	mux.HandleFunc("/dequeue/", s.logMiddleware(s.handleDequeue))
 	mux.HandleFunc("/skip", s.logMiddleware(s.handleSkip))
+	mux.HandleFunc("/abort", s.logMiddleware(s.handleAbort))
+	mux.HandleFunc("/resume", s.logMiddleware(s.handleResume))
+	mux.HandleFunc("/go-live", s.logMiddleware(s.handleGoLive))
 	mux.HandleFunc("/stop", s.logMiddleware(s.handleStop))
+	mux.HandleFunc("/restart", s.logMiddleware(s.handleRestart))
 	mux.HandleFunc("/progress", s.logMiddleware(s.handleProgress))
+	mux.HandleFunc("/progress/stream", s.logMiddleware(s.handleProgressStream))
+	mux.HandleFunc("/history", s.logMiddleware(s.handleHistory))
+	mux.HandleFunc("/report", s.logMiddleware(s.handleReport))
+	mux.HandleFunc("/ping-destination", s.logMiddleware(s.handlePingDestination))
 	mux.HandleFunc("/webrtc/status", s.logMiddleware(s.handleWebRTCStatus))
+	mux.HandleFunc("/webrtc/restream", s.logMiddleware(s.handleWebRTCRestream))
 	mux.HandleFunc("/files", s.logMiddleware(s.handleListFiles))
 	mux.HandleFunc("/files/", s.logMiddleware(s.handleServeFile))
+	mux.HandleFunc("/subtitle/info", s.logMiddleware(s.handleSubtitleInfo))
 	mux.HandleFunc("/log-level", s.logMiddleware(s.handleLogLevel))
+	mux.HandleFunc("/logs/app", s.logMiddleware(s.handleAppLogs))
+	mux.HandleFunc("/overlay/text", s.logMiddleware(s.handleOverlayText))
+	mux.HandleFunc("/defaults/overlay", s.logMiddleware(s.handleDefaultOverlay))
+	mux.HandleFunc("/version", s.logMiddleware(s.handleVersion))
+	mux.HandleFunc("/debug/ffmpeg-command", s.logMiddleware(s.handleFFmpegCommand))
+	mux.HandleFunc("/shutdown", s.logMiddleware(s.adminMiddleware(s.handleShutdown)))
 }
 
 func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
@@ -135,29 +378,94 @@ func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if cfg.Destination == "" {
+	if cfg.Destination == "" && len(cfg.Destinations) == 0 && cfg.RecordPath == "" {
 		s.logger.Warn("Missing destination parameter in start request")
 		http.Error(w, "Missing destination parameter", http.StatusBadRequest)
 		return
 	}
 
+	if cfg.RecordPath != "" {
+		recordPath, ok := s.allowedRecordPath(cfg.RecordPath)
+		if !ok {
+			s.logger.Warn("Recording path is outside the allowed directories", zap.String("path", cfg.RecordPath))
+			http.Error(w, "Recording path is outside the allowed directories", http.StatusForbidden)
+			return
+		}
+		cfg.RecordPath = recordPath
+	}
+
+	if cfg.IntroFile != "" && !s.isAllowedSource(cfg.IntroFile) {
+		s.logger.Warn("Intro file is outside the allowed source directories", zap.String("file", cfg.IntroFile))
+		http.Error(w, "Intro file is outside the allowed source directories", http.StatusForbidden)
+		return
+	}
+
+	if cfg.SlateImage != "" && !s.isAllowedSource(cfg.SlateImage) {
+		s.logger.Warn("Slate image is outside the allowed source directories", zap.String("file", cfg.SlateImage))
+		http.Error(w, "Slate image is outside the allowed source directories", http.StatusForbidden)
+		return
+	}
+
+	if err := streammanager.ValidateConfig(cfg); err != nil {
+		s.logger.Warn("Invalid start configuration", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := streammanager.CheckEncoderAvailable(r.Context(), cfg.Encoder); errors.Is(err, streammanager.ErrEncoderUnavailable) {
+		s.logger.Warn("Requested encoder is unavailable", zap.String("encoder", cfg.Encoder), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		// Preprocessing reports the encoder if it turns out to be missing
+		s.logger.Warn("Could not check the requested encoder", zap.String("encoder", cfg.Encoder), zap.Error(err))
+	}
+
 	// Set RTMP address if not provided
 	if cfg.RTMPAddr == "" {
 		cfg.RTMPAddr = s.rtmpAddr
 	}
 
+	queueEmpty := len(s.sm.Queue()) == 0
+	if queueEmpty && cfg.RequireQueue {
+		s.logger.Warn("Refusing to start with an empty queue")
+		http.Error(w, "Queue is empty, enqueue a file before starting", http.StatusBadRequest)
+		return
+	}
+
+	if !s.sm.TryStart(cfg) {
+		if wait, blocked := s.sm.StartBlocked(); blocked {
+			s.logger.Warn("Start requested while the last run is stopping", zap.Duration("retryAfter", wait))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "StreamManager is still stopping, retry shortly", http.StatusConflict)
+			return
+		}
+		s.logger.Warn("Start requested while already running")
+		http.Error(w, "StreamManager is already running", http.StatusConflict)
+		return
+	}
+
 	s.logger.Info("Starting stream manager",
 		zap.String("destination", cfg.Destination),
+		zap.Bool("streamKeySet", cfg.StreamKey != ""),
 		zap.String("rtmp_addr", cfg.RTMPAddr))
 
 	go func() {
-		if err := s.sm.Run(context.Background(), cfg); err != nil {
+		if err := s.sm.RunStarted(context.Background()); err != nil {
 			s.logger.Info("Stream manager stopped", zap.Error(err))
 		}
 	}()
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprint(w, "StreamManager started")
+	if queueEmpty {
+		s.writeJSON(w, map[string]string{
+			"status":  "ok",
+			"message": "StreamManager started",
+			"warning": "Queue is empty, streaming will begin once a file is enqueued",
+		})
+		return
+	}
+
+	s.writeOK(w, "StreamManager started")
 }
 
 func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) {
@@ -168,10 +476,9 @@ func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		File           string                        `json:"file"`
-		Overlay        streammanager.OverlaySettings `json:"overlay"`
-		StartTimestamp string                        `json:"startTimestamp,omitempty"` // Optional start timestamp
-		SubtitleFile   string                        `json:"subtitleFile,omitempty"`   // Optional subtitle file
+		File string `json:"file"`
+		streammanager.EntryOptions
+		Overlay *streammanager.OverlaySettings `json:"overlay"` // Nil when omitted, to tell it apart from an empty overlay
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -185,46 +492,123 @@ func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing file parameter", http.StatusBadRequest)
 		return
 	}
+	req.EntryOptions.Overlay = s.sm.EntryOverlay(req.Overlay)
 
-	var file string
-	var err error
+	if req.FadeIn < 0 || req.FadeOut < 0 {
+		s.logger.Warn("Negative fade duration in enqueue request",
+			zap.Float64("fadeIn", req.FadeIn),
+			zap.Float64("fadeOut", req.FadeOut))
+		http.Error(w, "Fade durations must not be negative", http.StatusBadRequest)
+		return
+	}
 
-	// Check if the file path is absolute or relative
-	if filepath.IsAbs(req.File) {
-		// If absolute, use as-is (for local file uploads)
-		file = req.File
-	} else {
-		// If relative, resolve against the configured file directory (for server files)
-		file = filepath.Join(s.fileDir, req.File)
+	if req.Volume < 0 {
+		s.logger.Warn("Negative volume in enqueue request", zap.Float64("volume", req.Volume))
+		http.Error(w, "Volume must not be negative", http.StatusBadRequest)
+		return
 	}
 
-	// Get absolute path and validate file exists
-	file, err = filepath.Abs(file)
+	// Absolute paths are used as-is (for local file uploads), relative ones
+	// resolve against the configured file directory (for server files)
+	file, err := s.resolveSourcePath(req.File)
 	if err != nil {
 		s.logger.Error("Failed to get absolute path for file",
 			zap.String("file", req.File),
-			zap.String("resolved", file),
 			zap.Error(err))
 		http.Error(w, "Unable to resolve file path", http.StatusBadRequest)
 		return
 	}
 
-	// Validate that the file exists
-	if _, err := os.Stat(file); os.IsNotExist(err) {
-		s.logger.Error("File does not exist",
+	// Files the entry draws on resolve the same way, before the sandbox check
+	if err := s.resolveSourcePaths(&req.EntryOptions.Overlay.WatermarkFile, &req.ImageFile, &req.SubtitleFile); err != nil {
+		s.logger.Error("Failed to get absolute path for entry file", zap.Error(err))
+		http.Error(w, "Unable to resolve file path", http.StatusBadRequest)
+		return
+	}
+
+	if req.EntryOptions.Overlay.WatermarkFile != "" && !s.isAllowedSource(req.EntryOptions.Overlay.WatermarkFile) {
+		s.logger.Warn("Watermark file is outside the allowed source directories",
+			zap.String("watermark", req.EntryOptions.Overlay.WatermarkFile))
+		http.Error(w, "Watermark file is outside the allowed source directories", http.StatusForbidden)
+		return
+	}
+
+	if req.ImageFile != "" && !s.isAllowedSource(req.ImageFile) {
+		s.logger.Warn("Image file is outside the allowed source directories",
+			zap.String("image", req.ImageFile))
+		http.Error(w, "Image file is outside the allowed source directories", http.StatusForbidden)
+		return
+	}
+
+	if req.SubtitleFile != "" && !s.isAllowedSource(req.SubtitleFile) {
+		s.logger.Warn("Subtitle file is outside the allowed source directories",
+			zap.String("subtitleFile", req.SubtitleFile))
+		http.Error(w, "Subtitle file is outside the allowed source directories", http.StatusForbidden)
+		return
+	}
+
+	if !s.isAllowedSource(file) {
+		s.logger.Warn("File is outside the allowed source directories",
 			zap.String("file", file),
 			zap.String("original", req.File))
-		http.Error(w, "File not found", http.StatusNotFound)
+		http.Error(w, "File is outside the allowed source directories", http.StatusForbidden)
 		return
 	}
 
-	id := s.sm.Enqueue(file, req.Overlay, req.StartTimestamp, req.SubtitleFile)
+	// Validate that the file exists and can be read, before ffmpeg fails on it
+	if err := streammanager.ValidateReadable(file); err != nil {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			s.logger.Error("File does not exist",
+				zap.String("file", file),
+				zap.String("original", req.File))
+			http.Error(w, "File not found", http.StatusNotFound)
+		case errors.Is(err, fs.ErrPermission):
+			s.logger.Error("File is not readable",
+				zap.String("file", file),
+				zap.String("original", req.File),
+				zap.Error(err))
+			http.Error(w, "File exists but is not readable", http.StatusForbidden)
+		default:
+			s.logger.Error("File cannot be enqueued",
+				zap.String("file", file),
+				zap.String("original", req.File),
+				zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	if err := s.sm.VerifyFileIntegrity(r.Context(), file, s.integrity); err != nil {
+		s.logger.Warn("File failed integrity check",
+			zap.String("file", file),
+			zap.String("mode", s.integrity),
+			zap.Error(err))
+		http.Error(w, "File failed integrity check: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err := s.sm.ValidateOutputDimensions(r.Context(), file); err != nil {
+		s.logger.Warn("File has an unsupported output resolution",
+			zap.String("file", file),
+			zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sm.ValidateEntryOptions(r.Context(), file, req.EntryOptions); err != nil {
+		s.logger.Warn("Invalid entry options",
+			zap.String("file", file),
+			zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := s.sm.Enqueue(file, req.EntryOptions)
 	s.logger.Info("File added to queue",
 		zap.String("file", file),
 		zap.String("id", id),
-		zap.String("startTimestamp", req.StartTimestamp),
-		zap.String("subtitleFile", req.SubtitleFile),
-		zap.Any("overlay", req.Overlay))
+		zap.Any("options", req.EntryOptions))
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]string{
@@ -235,13 +619,21 @@ func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleQueue handles GET and DELETE requests for the whole queue
 func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleGetQueue(w, r)
+	case http.MethodDelete:
+		s.handleClearQueue(w, r)
+	default:
 		s.logger.Warn("Invalid method for /queue endpoint", zap.String("method", r.Method))
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
 	}
+}
 
+// handleGetQueue returns the queued entries with the stream status
+func (s *Server) handleGetQueue(w http.ResponseWriter, r *http.Request) {
 	queue := s.sm.Queue()
 	status := s.sm.Status()
 
@@ -258,6 +650,268 @@ func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleClearQueue removes every queued entry, leaving the one playing
+func (s *Server) handleClearQueue(w http.ResponseWriter, r *http.Request) {
+	removed := s.sm.ClearQueue()
+	s.logger.Info("Queue cleared", zap.Int("removed", removed))
+	s.writeJSON(w, map[string]int{"removed": removed})
+}
+
+// handleQueueEntry dispatches /queue/{id}/{action} requests
+func (s *Server) handleQueueEntry(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/queue/"), "/")
+	if id == "next" && action == "" {
+		s.handleQueueNext(w, r)
+		return
+	}
+	if id == "count" && action == "" {
+		s.handleQueueCount(w, r)
+		return
+	}
+	if id == "reorder" && action == "" {
+		s.handleQueueReorder(w, r)
+		return
+	}
+	if id == "" {
+		s.logger.Warn("Missing queue entry id in queue request")
+		http.Error(w, "Missing queue entry id", http.StatusBadRequest)
+		return
+	}
+
+	switch action {
+	case "":
+		s.handleUpdateEntry(w, r, id)
+	case "up", "down":
+		s.handleMoveEntry(w, r, id, action)
+	case "poster":
+		s.handlePoster(w, r, id)
+	case "effective":
+		s.handleEffectiveSettings(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleQueueNext previews the next queue entry and its preprocessing command
+func (s *Server) handleQueueNext(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.logger.Warn("Invalid method for /queue/next endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	next, args, ok := s.sm.PreviewNext(r.Context())
+	if !ok {
+		s.writeJSON(w, map[string]any{
+			"empty": true,
+		})
+		return
+	}
+
+	s.writeJSON(w, map[string]any{
+		"empty": false,
+		"entry": next,
+		"args":  args,
+	})
+}
+
+// handleUpdateEntry changes the overlay, start timestamp or subtitle file of
+// a queued entry
+func (s *Server) handleUpdateEntry(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPatch {
+		s.logger.Warn("Invalid method for /queue/{id} endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var update streammanager.EntryUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		s.logger.Error("Failed to decode queue entry update", zap.Error(err))
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var watermarkFile *string
+	if update.Overlay != nil {
+		watermarkFile = update.Overlay.WatermarkFile
+	}
+	if err := s.resolveSourcePaths(update.SubtitleFile, watermarkFile); err != nil {
+		s.logger.Error("Failed to get absolute path for entry file", zap.Error(err))
+		http.Error(w, "Unable to resolve file path", http.StatusBadRequest)
+		return
+	}
+
+	if update.SubtitleFile != nil && *update.SubtitleFile != "" && !s.isAllowedSource(*update.SubtitleFile) {
+		s.logger.Warn("Subtitle file is outside the allowed source directories",
+			zap.String("subtitleFile", *update.SubtitleFile))
+		http.Error(w, "Subtitle file is outside the allowed source directories", http.StatusForbidden)
+		return
+	}
+
+	if update.Overlay != nil && update.Overlay.WatermarkFile != nil && *update.Overlay.WatermarkFile != "" &&
+		!s.isAllowedSource(*update.Overlay.WatermarkFile) {
+		s.logger.Warn("Watermark file is outside the allowed source directories",
+			zap.String("watermark", *update.Overlay.WatermarkFile))
+		http.Error(w, "Watermark file is outside the allowed source directories", http.StatusForbidden)
+		return
+	}
+
+	found, err := s.sm.Update(r.Context(), id, update)
+	if !found {
+		s.logger.Warn("Queue entry not found for update", zap.String("id", id))
+		http.Error(w, "Queue entry not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Warn("Invalid queue entry update", zap.String("id", id), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.logger.Info("Queue entry updated", zap.String("id", id))
+	s.writeOK(w, fmt.Sprintf("Queue entry %s updated", id))
+}
+
+// handleEffectiveSettings reports the settings a queued entry will play
+// with, after server defaults and config are applied to its own options
+func (s *Server) handleEffectiveSettings(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		s.logger.Warn("Invalid method for /queue/{id}/effective endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	settings, ok := s.sm.EffectiveSettings(r.Context(), id)
+	if !ok {
+		http.Error(w, "Queue entry not found", http.StatusNotFound)
+		return
+	}
+	s.writeJSON(w, settings)
+}
+
+// handleQueueCount reports the running state and queue length for clients
+// polling too often for the full /queue
+func (s *Server) handleQueueCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.logger.Warn("Invalid method for /queue/count endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.writeJSON(w, s.sm.QueueCount())
+}
+
+// handleFFmpegCommand reports the command of the running streaming ffmpeg,
+// the live counterpart to /queue/next
+func (s *Server) handleFFmpegCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.logger.Warn("Invalid method for /debug/ffmpeg-command endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	args, ok := s.sm.StreamingCommand()
+	if !ok {
+		s.writeJSON(w, map[string]any{
+			"running": false,
+		})
+		return
+	}
+
+	s.writeJSON(w, map[string]any{
+		"running": true,
+		"args":    args,
+	})
+}
+
+// handlePoster serves a JPEG frame from the entry at its start timestamp
+func (s *Server) handlePoster(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		s.logger.Warn("Invalid method for /queue/{id}/poster endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	poster, found, err := s.sm.Poster(r.Context(), id)
+	if !found {
+		http.Error(w, "Queue entry not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to extract poster", zap.String("id", id), zap.Error(err))
+		http.Error(w, "Failed to extract poster", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if _, err := w.Write(poster); err != nil {
+		s.logger.Error("Failed to write poster", zap.Error(err))
+	}
+}
+
+func (s *Server) handleMoveEntry(w http.ResponseWriter, r *http.Request, id, direction string) {
+	if r.Method != http.MethodPost {
+		s.logger.Warn("Invalid method for /queue/{id}/"+direction+" endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var found bool
+	if direction == "up" {
+		found = s.sm.MoveUp(id)
+	} else {
+		found = s.sm.MoveDown(id)
+	}
+
+	if !found {
+		s.logger.Warn("Queue entry not found for move", zap.String("id", id), zap.String("direction", direction))
+		http.Error(w, "Queue entry not found", http.StatusNotFound)
+		return
+	}
+
+	s.logger.Info("Queue entry moved", zap.String("id", id), zap.String("direction", direction))
+	s.writeOK(w, fmt.Sprintf("Queue entry %s moved %s", id, direction))
+}
+
+// handleQueueReorder moves a queued entry to a position in the queue
+func (s *Server) handleQueueReorder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.logger.Warn("Invalid method for /queue/reorder endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID       string `json:"id"`
+		Position *int   `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("Failed to decode queue reorder request", zap.Error(err))
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" || req.Position == nil {
+		http.Error(w, "Missing id or position", http.StatusBadRequest)
+		return
+	}
+
+	found, err := s.sm.Reorder(req.ID, *req.Position)
+	if !found {
+		s.logger.Warn("Queue entry not found for reorder", zap.String("id", req.ID))
+		http.Error(w, "Queue entry not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Warn("Invalid queue reorder position", zap.String("id", req.ID), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.logger.Info("Queue entry reordered", zap.String("id", req.ID), zap.Int("position", *req.Position))
+	s.writeOK(w, fmt.Sprintf("Queue entry %s moved to position %d", req.ID, *req.Position))
+}
+
 func (s *Server) handleDequeue(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		s.logger.Warn("Invalid method for /dequeue endpoint", zap.String("method", r.Method))
@@ -274,8 +928,7 @@ func (s *Server) handleDequeue(w http.ResponseWriter, r *http.Request) {
 
 	if s.sm.Dequeue(id) {
 		s.logger.Info("Queue entry removed", zap.String("id", id))
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "Queue entry %s removed", id)
+		s.writeOK(w, fmt.Sprintf("Queue entry %s removed", id))
 	} else {
 		s.logger.Warn("Queue entry not found for dequeue", zap.String("id", id))
 		http.Error(w, "Queue entry not found", http.StatusNotFound)
@@ -291,14 +944,62 @@ func (s *Server) handleSkip(w http.ResponseWriter, r *http.Request) {
 
 	if s.sm.Skip() {
 		s.logger.Info("Current file processing was skipped")
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, "Current file skipped")
+		s.writeOK(w, "Current file skipped")
 	} else {
 		s.logger.Warn("Skip requested but no file currently being processed")
 		http.Error(w, "No file currently being processed", http.StatusBadRequest)
 	}
 }
 
+func (s *Server) handleAbort(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.logger.Warn("Invalid method for /abort endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.sm.Abort() {
+		s.logger.Info("Current file processing was aborted, queue held")
+		s.writeOK(w, "Current file aborted, queue held")
+	} else {
+		s.logger.Warn("Abort requested but no file currently being processed")
+		http.Error(w, "No file currently being processed", http.StatusBadRequest)
+	}
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.logger.Warn("Invalid method for /resume endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.sm.Resume() {
+		s.logger.Info("Queue resumed")
+		s.writeOK(w, "Queue resumed")
+	} else {
+		s.logger.Warn("Resume requested but queue is not held")
+		http.Error(w, "Queue is not held", http.StatusBadRequest)
+	}
+}
+
+// handleGoLive ends the standby of a stream started paused
+func (s *Server) handleGoLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.logger.Warn("Invalid method for /go-live endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.sm.GoLive() {
+		s.logger.Info("Stream going live")
+		s.writeOK(w, "Stream going live")
+	} else {
+		s.logger.Warn("Go-live requested but stream is not standing by")
+		http.Error(w, "Stream is not standing by", http.StatusConflict)
+	}
+}
+
 func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		s.logger.Warn("Invalid method for /stop endpoint", zap.String("method", r.Method))
@@ -308,14 +1009,45 @@ func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
 
 	if s.sm.Stop() {
 		s.logger.Info("Stream manager stopped")
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, "Stream manager stopped")
+		s.writeOK(w, "Stream manager stopped")
 	} else {
 		s.logger.Warn("Stop requested but stream manager not running")
 		http.Error(w, "Stream manager not running", http.StatusBadRequest)
 	}
 }
 
+// handleRestart stops any running stream, waits for its teardown and the
+// start grace period, then starts again with the config in the body exactly
+// as /start does
+func (s *Server) handleRestart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.logger.Warn("Invalid method for /restart endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.sm.Stop() {
+		s.logger.Info("Stream manager stopped for restart")
+	}
+
+	select {
+	case <-s.sm.TeardownComplete():
+	case <-r.Context().Done():
+		return
+	}
+	if wait, blocked := s.sm.StartBlocked(); blocked {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	s.handleStart(w, r)
+}
+
 func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		s.logger.Warn("Invalid method for /progress endpoint", zap.String("method", r.Method))
@@ -324,16 +1056,196 @@ func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
 	}
 
 	progress, hasProgress := s.sm.GetLatestProgress()
+	stalledSeconds, stalled := s.sm.StallStatus()
+	speedFactor, keepingUp := s.sm.SpeedStatus()
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]any{
-		"hasProgress": hasProgress,
-		"progress":    progress,
+		"hasProgress":    hasProgress,
+		"progress":       progress,
+		"stalledSeconds": stalledSeconds,
+		"stalled":        stalled,
+		"speedFactor":    speedFactor,
+		"keepingUp":      keepingUp,
 	}); err != nil {
 		s.logger.Error("Failed to encode progress response", zap.Error(err))
 	}
 }
 
+// handleProgressStream sends each progress update as a server-sent event
+// until the client disconnects, starting with the latest update if any
+func (s *Server) handleProgressStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.logger.Warn("Invalid method for /progress/stream endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Subscribe before reading the latest so no update falls in between
+	updates, unsubscribe := s.sm.SubscribeProgress()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	rc := http.NewResponseController(w)
+	if progress, ok := s.sm.GetLatestProgress(); ok {
+		if err := writeEvent(w, progress); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		s.logger.Error("Progress stream is not supported by the connection", zap.Error(err))
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		case progress := <-updates:
+			if err := writeEvent(w, progress); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes v as the JSON data of a server-sent event
+func writeEvent(w http.ResponseWriter, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+// handleOverlayText replaces the text shown by the live text overlay
+func (s *Server) handleOverlayText(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		s.logger.Warn("Invalid method for /overlay/text endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("Failed to decode overlay text request", zap.Error(err))
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	found, err := s.sm.SetOverlayText(req.Text)
+	if !found {
+		http.Error(w, "Live text overlay is not enabled for the current stream", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to update overlay text", zap.Error(err))
+		http.Error(w, "Failed to update overlay text", http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.Info("Overlay text updated", zap.String("text", req.Text))
+	s.writeOK(w, "Overlay text updated")
+}
+
+// handlePingDestination publishes a short test pattern to check a destination
+func (s *Server) handlePingDestination(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.logger.Warn("Invalid method for /ping-destination endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Destination string `json:"destination"`
+		StreamKey   string `json:"streamKey"` // Appended to Destination as the final path segment, as when streaming
+		Username    string `json:"username"`
+		Password    string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("Failed to decode ping destination request", zap.Error(err))
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Destination == "" {
+		http.Error(w, "Missing destination parameter", http.StatusBadRequest)
+		return
+	}
+
+	resp := map[string]any{"reachable": true}
+	if err := streammanager.PingDestination(r.Context(), req.Destination, req.StreamKey, req.Username, req.Password); err != nil {
+		s.logger.Warn("Destination ping failed", zap.String("destination", req.Destination), zap.Error(err))
+		resp["reachable"] = false
+		resp["error"] = err.Error()
+	} else {
+		s.logger.Info("Destination ping succeeded", zap.String("destination", req.Destination))
+	}
+
+	s.writeJSON(w, resp)
+}
+
+// handleHistory returns (GET) or clears (DELETE) the play history
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, map[string]any{
+			"history": s.sm.History(),
+		})
+	case http.MethodDelete:
+		removed := s.sm.ClearHistory()
+		s.logger.Info("Play history cleared", zap.Int("removed", removed))
+		s.writeJSON(w, map[string]int{
+			"removed": removed,
+		})
+	default:
+		s.logger.Warn("Invalid method for /history endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleReport serves the report of the current or last session as JSON, or
+// its entries as CSV with ?format=csv
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.logger.Warn("Invalid method for /report endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, ok := s.sm.Report()
+	if !ok {
+		http.Error(w, "No session has run yet", http.StatusNotFound)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		s.writeJSON(w, report)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"report-%s.csv\"",
+			report.StartedAt.UTC().Format("20060102-150405")))
+		if err := report.WriteCSV(w); err != nil {
+			s.logger.Error("Failed to write CSV report", zap.Error(err))
+		}
+	default:
+		http.Error(w, "Unsupported format, must be json or csv", http.StatusBadRequest)
+	}
+}
+
 func (s *Server) handleWebRTCStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		s.logger.Warn("Invalid method for /webrtc/status endpoint", zap.String("method", r.Method))
@@ -354,6 +1266,58 @@ func (s *Server) handleWebRTCStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleWebRTCRestream configures (POST) or stops (DELETE) forwarding the
+// WHIP broadcast to an RTMP destination
+func (s *Server) handleWebRTCRestream(w http.ResponseWriter, r *http.Request) {
+	if s.restreamer == nil {
+		http.Error(w, "WebRTC restreaming not available", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			Destination string `json:"destination"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.logger.Error("Failed to decode restream request", zap.Error(err))
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.Destination == "" {
+			http.Error(w, "Missing destination parameter", http.StatusBadRequest)
+			return
+		}
+
+		s.restreamMu.Lock()
+		s.restreamDest = req.Destination
+		s.restreamMu.Unlock()
+
+		// Start immediately if a broadcast is already live
+		if broadcasting, _ := s.webrtcSrv.GetStatus()["broadcasting"].(bool); broadcasting {
+			s.startRestream()
+		}
+
+		s.logger.Info("WHIP restream destination set", zap.String("destination", req.Destination))
+		s.writeOK(w, "WHIP restream destination set")
+	case http.MethodDelete:
+		s.restreamMu.Lock()
+		s.restreamDest = ""
+		if s.restreamCancel != nil {
+			s.restreamCancel()
+		}
+		s.restreamMu.Unlock()
+
+		s.logger.Info("WHIP restream disabled")
+		s.writeOK(w, "WHIP restream disabled")
+	default:
+		s.logger.Warn("Invalid method for /webrtc/restream endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // FileInfo represents a file entry for the API
 type FileInfo struct {
 	Name    string `json:"name"`
@@ -526,6 +1490,98 @@ func isSubtitleFile(filename string) bool {
 	return slices.Contains(subtitleExtensions, ext)
 }
 
+// handleSubtitleInfo reports whether a subtitle file can be burned in: its
+// format, detected character encoding and how many cues it has
+func (s *Server) handleSubtitleInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.logger.Warn("Invalid method for /subtitle/info endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	file := r.URL.Query().Get("file")
+	if file == "" {
+		http.Error(w, "File parameter is required", http.StatusBadRequest)
+		return
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(s.fileDir, file)
+	}
+	file, err := filepath.Abs(file)
+	if err != nil {
+		http.Error(w, "Unable to resolve file path", http.StatusBadRequest)
+		return
+	}
+
+	if !s.isAllowedSource(file) {
+		s.logger.Warn("Subtitle file is outside the allowed source directories", zap.String("file", file))
+		http.Error(w, "File is outside the allowed source directories", http.StatusForbidden)
+		return
+	}
+	if !isSubtitleFile(file) {
+		http.Error(w, "File is not a supported subtitle format", http.StatusBadRequest)
+		return
+	}
+	if fi, err := os.Stat(file); err != nil || fi.IsDir() {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	info, err := streammanager.InspectSubtitleFile(file)
+	if err != nil {
+		s.logger.Error("Failed to inspect subtitle file", zap.String("file", file), zap.Error(err))
+		http.Error(w, fmt.Sprintf("Failed to inspect subtitle file: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, map[string]any{
+		"file":     file,
+		"format":   info.Format,
+		"encoding": info.Encoding,
+		"utf8":     info.UTF8,
+		"cues":     info.Cues,
+		"valid":    info.Valid,
+		"error":    info.Error,
+	})
+}
+
+// handleAppLogs returns recent application log entries, optionally filtered
+// by minimum level and limited in count
+func (s *Server) handleAppLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.logger.Warn("Invalid method for /logs/app endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.logBuffer == nil {
+		http.Error(w, "Application logs not available", http.StatusNotFound)
+		return
+	}
+
+	level := zapcore.DebugLevel
+	if l := r.URL.Query().Get("level"); l != "" {
+		if err := level.UnmarshalText([]byte(strings.ToLower(l))); err != nil {
+			http.Error(w, "Invalid log level: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	s.writeJSON(w, map[string]any{
+		"entries": s.logBuffer.Entries(level, limit),
+	})
+}
+
 // handleLogLevel handles GET and POST requests for application log level
 func (s *Server) handleLogLevel(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -600,3 +1656,76 @@ func (s *Server) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
 		s.logger.Error("Failed to encode log level response", zap.Error(err))
 	}
 }
+
+// handleDefaultOverlay returns or replaces the overlay applied to entries
+// enqueued without their own
+func (s *Server) handleDefaultOverlay(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, s.sm.DefaultOverlay())
+	case http.MethodPost:
+		var overlay streammanager.OverlaySettings
+		if err := json.NewDecoder(r.Body).Decode(&overlay); err != nil {
+			s.logger.Error("Failed to decode default overlay request", zap.Error(err))
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if overlay.WatermarkFile != "" && !s.isAllowedSource(overlay.WatermarkFile) {
+			s.logger.Warn("Watermark file is outside the allowed source directories",
+				zap.String("watermark", overlay.WatermarkFile))
+			http.Error(w, "Watermark file is outside the allowed source directories", http.StatusForbidden)
+			return
+		}
+		if err := s.sm.SetDefaultOverlay(overlay); err != nil {
+			s.logger.Warn("Invalid default overlay", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Info("Default overlay updated",
+			zap.Bool("showFilename", overlay.ShowFilename),
+			zap.String("position", overlay.Position),
+			zap.Int("fontSize", overlay.FontSize))
+		s.writeJSON(w, overlay)
+	default:
+		s.logger.Warn("Invalid method for /defaults/overlay endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleVersion reports the detected ffmpeg version and whether it is
+// supported without legacy flag substitutions
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.logger.Warn("Invalid method for /version endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	version := streammanager.CurrentFFmpegVersion()
+	s.writeJSON(w, map[string]any{
+		"ffmpeg":        version,
+		"detected":      version.Raw != "",
+		"supported":     version.Supported(),
+		"minimumFFmpeg": streammanager.MinFFmpegVersion().Raw,
+	})
+}
+
+// handleShutdown begins the same graceful shutdown as SIGTERM
+func (s *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.logger.Warn("Invalid method for /shutdown endpoint", zap.String("method", r.Method))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.shutdown == nil {
+		http.Error(w, "Shutdown is not available", http.StatusNotImplemented)
+		return
+	}
+
+	s.logger.Info("Shutdown requested", zap.String("remote_addr", r.RemoteAddr))
+	s.writeOK(w, "Shutting down")
+
+	// Let the response go out before the HTTP server starts shutting down
+	go s.shutdown()
+}
@@ -0,0 +1,303 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := New(zap.NewNop(), "", nil, filepath.Join(t.TempDir(), "test.fifo"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	s.fileDir = t.TempDir()
+	return s
+}
+
+func TestIsWithinDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		dir      string
+		path     string
+		expected bool
+	}{
+		{name: "the directory itself", dir: "/media", path: "/media", expected: true},
+		{name: "file inside", dir: "/media", path: "/media/video.mp4", expected: true},
+		{name: "nested file", dir: "/media", path: "/media/shows/s01/e01.mkv", expected: true},
+		{name: "file outside", dir: "/media", path: "/etc/passwd"},
+		{name: "parent directory", dir: "/media", path: "/"},
+		{name: "sibling with a shared prefix", dir: "/media", path: "/media2/video.mp4"},
+		{name: "traversal out of the directory", dir: "/media", path: "/media/../etc/passwd"},
+		{name: "name starting with dots", dir: "/media", path: "/media/..hidden.mp4", expected: true},
+		{name: "relative path", dir: "/media", path: "video.mp4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isWithinDir(tt.dir, tt.path); got != tt.expected {
+				t.Errorf("isWithinDir(%q, %q) = %v, want %v", tt.dir, tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsAllowedSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourceDirs []string
+		file       string
+		expected   bool
+	}{
+		{name: "no allowlist allows any path", file: "/etc/passwd", expected: true},
+		{name: "file directory", sourceDirs: []string{"/srv/media"}, file: "/files/video.mp4", expected: true},
+		{name: "allowed directory", sourceDirs: []string{"/srv/media"}, file: "/srv/media/video.mp4", expected: true},
+		{name: "second allowed directory", sourceDirs: []string{"/srv/media", "/mnt/nas"}, file: "/mnt/nas/subs.srt", expected: true},
+		{name: "outside every directory", sourceDirs: []string{"/srv/media"}, file: "/etc/passwd"},
+		{name: "traversal out of an allowed directory", sourceDirs: []string{"/srv/media"}, file: "/srv/media/../../etc/passwd"},
+		{name: "relative path", sourceDirs: []string{"/srv/media"}, file: "../etc/passwd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{fileDir: "/files", sourceDirs: tt.sourceDirs}
+			if got := s.isAllowedSource(tt.file); got != tt.expected {
+				t.Errorf("isAllowedSource(%q) = %v, want %v", tt.file, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnqueueSubtitleSandbox(t *testing.T) {
+	tests := []struct {
+		name          string
+		subtitleFile  string
+		watermarkFile string
+		imageFile     string
+		expected      int
+	}{
+		{name: "subtitle outside the allowed directories", subtitleFile: "/etc/passwd", expected: http.StatusForbidden},
+		{name: "traversal out of the file directory", subtitleFile: "../../etc/passwd", expected: http.StatusForbidden},
+		// Relative paths resolve against the file directory like the main file
+		{name: "subtitle in the file directory", subtitleFile: "subs.srt", expected: http.StatusNotFound},
+		{name: "watermark in the file directory", watermarkFile: "logo.png", expected: http.StatusNotFound},
+		{name: "image in the file directory", imageFile: "cover.png", expected: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			if err := s.SetAllowedSourceDirs([]string{t.TempDir()}); err != nil {
+				t.Fatalf("SetAllowedSourceDirs() error = %v", err)
+			}
+
+			body := `{"file":"missing.mp4","subtitleFile":"` + tt.subtitleFile + `","imageFile":"` + tt.imageFile +
+				`","overlay":{"watermarkFile":"` + tt.watermarkFile + `"}}`
+			rec := httptest.NewRecorder()
+			s.handleEnqueue(rec, httptest.NewRequest(http.MethodPost, "/enqueue", strings.NewReader(body)))
+
+			// A subtitle that passes the sandbox gets as far as the missing source
+			if rec.Code != tt.expected {
+				t.Errorf("POST /enqueue status = %d, want %d: %s", rec.Code, tt.expected, rec.Body)
+			}
+		})
+	}
+}
+
+func TestUpdateEntrySandbox(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{name: "subtitle outside the allowed directories", body: `{"subtitleFile":"/etc/passwd"}`, expected: http.StatusForbidden},
+		{name: "watermark outside the allowed directories", body: `{"overlay":{"watermarkFile":"../../etc/passwd"}}`, expected: http.StatusForbidden},
+		// Relative paths resolve against the file directory and pass the
+		// sandbox, reaching the lookup of the missing entry
+		{name: "subtitle in the file directory", body: `{"subtitleFile":"subs.srt"}`, expected: http.StatusNotFound},
+		{name: "watermark in the file directory", body: `{"overlay":{"watermarkFile":"logo.png"}}`, expected: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			if err := s.SetAllowedSourceDirs([]string{t.TempDir()}); err != nil {
+				t.Fatalf("SetAllowedSourceDirs() error = %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			s.handleUpdateEntry(rec, httptest.NewRequest(http.MethodPatch, "/queue/missing", strings.NewReader(tt.body)), "missing")
+			if rec.Code != tt.expected {
+				t.Errorf("PATCH /queue/missing status = %d, want %d: %s", rec.Code, tt.expected, rec.Body)
+			}
+		})
+	}
+}
+
+func TestStartSlateSandbox(t *testing.T) {
+	tests := []struct {
+		name       string
+		slateImage string
+		expected   int
+	}{
+		{name: "slate outside the allowed directories", slateImage: "/etc/passwd", expected: http.StatusForbidden},
+		{name: "slate in the file directory", slateImage: "slate.png", expected: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			if err := s.SetAllowedSourceDirs([]string{t.TempDir()}); err != nil {
+				t.Fatalf("SetAllowedSourceDirs() error = %v", err)
+			}
+
+			slateImage := tt.slateImage
+			if !filepath.IsAbs(slateImage) {
+				slateImage = filepath.Join(s.fileDir, slateImage)
+			}
+			// The negative timeout fails validation once the slate passes the sandbox
+			body := `{"destination":"rtmp://localhost/live","connectTimeout":-1,"slateImage":"` + slateImage + `"}`
+			rec := httptest.NewRecorder()
+			s.handleStart(rec, httptest.NewRequest(http.MethodPost, "/start", strings.NewReader(body)))
+
+			if rec.Code != tt.expected {
+				t.Errorf("POST /start status = %d, want %d: %s", rec.Code, tt.expected, rec.Body)
+			}
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	s := newTestServer(t)
+	called := make(chan struct{})
+	s.SetShutdownFunc(func() { close(called) })
+
+	rec := httptest.NewRecorder()
+	s.handleShutdown(rec, httptest.NewRequest(http.MethodPost, "/shutdown", nil))
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("POST /shutdown = %d %q, want 200 application/json", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Error("POST /shutdown did not start the shutdown")
+	}
+}
+
+func TestFFmpegCommand(t *testing.T) {
+	tests := []struct {
+		method   string
+		expected int
+		body     string
+	}{
+		{method: http.MethodGet, expected: http.StatusOK, body: `"running":false`},
+		{method: http.MethodPost, expected: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			s := newTestServer(t)
+			rec := httptest.NewRecorder()
+			s.handleFFmpegCommand(rec, httptest.NewRequest(tt.method, "/debug/ffmpeg-command", nil))
+
+			if rec.Code != tt.expected || !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("%s /debug/ffmpeg-command = %d %s, want %d with %s", tt.method, rec.Code, rec.Body, tt.expected, tt.body)
+			}
+		})
+	}
+}
+
+// fakeRestreamer records restreams of a WHIP broadcast, each lasting until cancelled
+type fakeRestreamer struct {
+	broadcasting bool
+	onStart      func()
+	started      chan string
+	stopped      chan string
+}
+
+func (f *fakeRestreamer) GetStatus() map[string]any {
+	return map[string]any{"broadcasting": f.broadcasting}
+}
+
+func (f *fakeRestreamer) OnBroadcastStart(fn func()) {
+	f.onStart = fn
+}
+
+func (f *fakeRestreamer) Restream(ctx context.Context, destination string) error {
+	f.started <- destination
+	<-ctx.Done()
+	f.stopped <- destination
+	return ctx.Err()
+}
+
+func TestWebRTCRestream(t *testing.T) {
+	s := newTestServer(t)
+	restreamer := &fakeRestreamer{started: make(chan string, 2), stopped: make(chan string, 2)}
+	s.SetWebRTCServer(restreamer)
+
+	request := func(method, body string) int {
+		rec := httptest.NewRecorder()
+		s.handleWebRTCRestream(rec, httptest.NewRequest(method, "/webrtc/restream", strings.NewReader(body)))
+		return rec.Code
+	}
+	receive := func(ch chan string, what string) string {
+		t.Helper()
+		select {
+		case dest := <-ch:
+			return dest
+		case <-time.After(time.Second):
+			t.Fatalf("restream was not %s", what)
+			return ""
+		}
+	}
+
+	if code := request(http.MethodPost, `{}`); code != http.StatusBadRequest {
+		t.Errorf("POST without a destination = %d, want 400", code)
+	}
+
+	// Nothing is live yet, so the restream waits for a broadcast
+	if code := request(http.MethodPost, `{"destination":"rtmp://localhost/live/out"}`); code != http.StatusOK {
+		t.Fatalf("POST = %d, want 200", code)
+	}
+	select {
+	case dest := <-restreamer.started:
+		t.Fatalf("restream to %s started without a broadcast", dest)
+	default:
+	}
+
+	restreamer.onStart()
+	if dest := receive(restreamer.started, "started"); dest != "rtmp://localhost/live/out" {
+		t.Errorf("restream destination = %q, want rtmp://localhost/live/out", dest)
+	}
+
+	// A second broadcast start while one restream runs does not add another
+	restreamer.onStart()
+	select {
+	case <-restreamer.started:
+		t.Error("a second restream started while one was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if code := request(http.MethodDelete, ""); code != http.StatusOK {
+		t.Errorf("DELETE = %d, want 200", code)
+	}
+	receive(restreamer.stopped, "stopped")
+}
+
+func TestWebRTCRestreamUnavailable(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.handleWebRTCRestream(rec, httptest.NewRequest(http.MethodPost, "/webrtc/restream", strings.NewReader(`{"destination":"rtmp://localhost/live"}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("POST without a WebRTC server = %d, want 404", rec.Code)
+	}
+}
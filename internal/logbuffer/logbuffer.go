@@ -0,0 +1,128 @@
+package logbuffer
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is a captured log entry
+type Entry struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Logger  string         `json:"logger,omitempty"`
+	Message string         `json:"message"`
+	Caller  string         `json:"caller,omitempty"`
+	Fields  map[string]any `json:"fields,omitempty"`
+
+	level zapcore.Level
+}
+
+// Buffer is a fixed size ring of the most recent log entries
+type Buffer struct {
+	mu      sync.RWMutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// New creates a Buffer holding up to size entries
+func New(size int) *Buffer {
+	return &Buffer{
+		entries: make([]Entry, max(size, 1)),
+	}
+}
+
+func (b *Buffer) add(e Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries[b.next] = e
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Entries returns up to limit of the most recent entries at or above level,
+// oldest first. A limit of zero or less returns all matching entries.
+func (b *Buffer) Entries(level zapcore.Level, limit int) []Entry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var ordered []Entry
+	if b.full {
+		ordered = append(ordered, b.entries[b.next:]...)
+	}
+	ordered = append(ordered, b.entries[:b.next]...)
+
+	result := make([]Entry, 0, len(ordered))
+	for _, e := range ordered {
+		if e.level >= level {
+			result = append(result, e)
+		}
+	}
+
+	if limit > 0 && len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+	return result
+}
+
+// Core returns a zapcore.Core that records entries enabled by enab into the buffer
+func (b *Buffer) Core(enab zapcore.LevelEnabler) zapcore.Core {
+	return &core{LevelEnabler: enab, buf: b}
+}
+
+type core struct {
+	zapcore.LevelEnabler
+	buf    *Buffer
+	fields []zapcore.Field
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{
+		LevelEnabler: c.LevelEnabler,
+		buf:          c.buf,
+		fields:       append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	e := Entry{
+		Time:    ent.Time,
+		Level:   ent.Level.String(),
+		Logger:  ent.LoggerName,
+		Message: ent.Message,
+		level:   ent.Level,
+	}
+	if ent.Caller.Defined {
+		e.Caller = ent.Caller.TrimmedPath()
+	}
+	if len(enc.Fields) > 0 {
+		e.Fields = enc.Fields
+	}
+
+	c.buf.add(e)
+	return nil
+}
+
+func (c *core) Sync() error {
+	return nil
+}
@@ -0,0 +1,58 @@
+package logbuffer
+
+import (
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestBufferRing(t *testing.T) {
+	buf := New(3)
+	logger := zap.New(buf.Core(zapcore.DebugLevel))
+
+	for i := range 5 {
+		logger.Info(fmt.Sprintf("message %d", i))
+	}
+
+	entries := buf.Entries(zapcore.DebugLevel, 0)
+	if len(entries) != 3 {
+		t.Fatalf("len(Entries()) = %d, want 3", len(entries))
+	}
+	for i, want := range []string{"message 2", "message 3", "message 4"} {
+		if entries[i].Message != want {
+			t.Errorf("Entries()[%d].Message = %q, want %q", i, entries[i].Message, want)
+		}
+	}
+
+	if got := buf.Entries(zapcore.DebugLevel, 1); len(got) != 1 || got[0].Message != "message 4" {
+		t.Errorf("Entries(limit=1) = %v, want only the newest entry", got)
+	}
+}
+
+func TestBufferLevels(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	buf := New(10)
+	logger := zap.New(buf.Core(level)).With(zap.String("component", "test"))
+
+	logger.Debug("dropped")
+	logger.Info("info", zap.Int("n", 1))
+	logger.Warn("warn")
+
+	level.SetLevel(zapcore.DebugLevel)
+	logger.Debug("debug")
+
+	all := buf.Entries(zapcore.DebugLevel, 0)
+	if len(all) != 3 {
+		t.Fatalf("len(Entries()) = %d, want 3: %v", len(all), all)
+	}
+	if all[0].Fields["component"] != "test" || all[0].Fields["n"] != int64(1) {
+		t.Errorf("Entries()[0].Fields = %v, want component and n", all[0].Fields)
+	}
+
+	warn := buf.Entries(zapcore.WarnLevel, 0)
+	if len(warn) != 1 || warn[0].Message != "warn" {
+		t.Errorf("Entries(warn) = %v, want only the warning", warn)
+	}
+}
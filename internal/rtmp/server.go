@@ -2,75 +2,163 @@ package rtmp
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"net"
 	"os"
 	"path"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/MemeLabs/strims/pkg/rtmpingress"
 	"go.uber.org/zap"
 )
 
+// pruneInterval is how often the cleaner applies the retention policy
+const pruneInterval = time.Minute
+
 type Server struct {
-	server    *rtmpingress.Server
-	logger    *zap.Logger
-	tsfolders []string
+	server          *rtmpingress.Server
+	logger          *zap.Logger
+	mu              sync.Mutex
+	tsfolders       []recording
+	maxCount        int
+	maxAge          time.Duration
+	segmentSize     int64         // Bytes per recording file before starting a new one
+	segmentDuration time.Duration // Time per recording file before starting a new one
+	stop            chan struct{}
+	stopOnce        sync.Once
+}
+
+// recording is a directory of segments written for one ingested stream
+type recording struct {
+	path    string
+	started time.Time
+	active  bool
+}
+
+// Recording describes a retained recording directory
+type Recording struct {
+	Path    string    `json:"path"`
+	Started time.Time `json:"started"`
+	Active  bool      `json:"active"` // Still being written by a live stream
+	Size    int64     `json:"size"`   // Total bytes of its segments
 }
 
+// tw writes the fragmented MP4 segments of one stream into numbered files.
+// Each segment arrives as its init section, prefixed with a 2-byte length,
+// followed by its fragments and a Flush. Segments are appended to the open
+// file until it reaches maxSize or maxDuration; with neither set every
+// segment gets its own file.
 type tw struct {
-	i    int
-	path string
-	file io.WriteCloser
+	mu          sync.Mutex
+	i           int
+	path        string
+	file        *os.File
+	size        int64
+	opened      time.Time
+	inSegment   bool // The current segment's init section has been written
+	maxSize     int64
+	maxDuration time.Duration
 }
 
-func newTw() *tw {
+func newTw(maxSize int64, maxDuration time.Duration) (*tw, error) {
 	tmp, err := os.MkdirTemp("", "streammanager")
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
 	}
-	return &tw{path: tmp}
+	return &tw{path: tmp, maxSize: maxSize, maxDuration: maxDuration}, nil
 }
 
 func (t *tw) Write(p []byte) (int, error) {
-	if t.file == nil {
-		f, err := os.Create(path.Join(t.path, fmt.Sprintf("%d.mp4", t.i)))
-		if err != nil {
-			return 0, err
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.inSegment {
+		if t.file == nil {
+			return 0, errors.New("segment data written after the recording was closed")
 		}
-		t.file = f
-		t.i++
+		return t.write(p)
+	}
 
-		// trim moov atom length header
-		p = p[2:]
+	// Start of a segment: its init section with the moov atom length header
+	if len(p) < 2 {
+		return 0, fmt.Errorf("init section of %d bytes is missing its length header", len(p))
 	}
+	t.inSegment = true
 
-	n, err := t.file.Write(p)
+	// The open file already starts with an init section
+	if t.file != nil {
+		return len(p), nil
+	}
+
+	f, err := os.Create(path.Join(t.path, fmt.Sprintf("%d.mp4", t.i)))
 	if err != nil {
 		return 0, err
 	}
+	t.file = f
+	t.size = 0
+	t.opened = time.Now()
+	t.i++
 
-	return n, nil
+	// trim moov atom length header
+	n, err := t.write(p[2:])
+	return n + 2, err
+}
+
+// write appends p to the open file. The caller must hold t.mu.
+func (t *tw) write(p []byte) (int, error) {
+	n, err := t.file.Write(p)
+	t.size += int64(n)
+	return n, err
 }
 
 func (t *tw) Flush() error {
-	if err := t.file.Close(); err != nil {
-		return err
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.inSegment = false
+	if t.file == nil || !t.full() {
+		return nil
 	}
+	return t.closeFile()
+}
 
-	t.file = nil
+// full reports whether the open file should be finished at the end of the
+// current segment. The caller must hold t.mu.
+func (t *tw) full() bool {
+	if t.maxSize <= 0 && t.maxDuration <= 0 {
+		return true
+	}
+	return (t.maxSize > 0 && t.size >= t.maxSize) ||
+		(t.maxDuration > 0 && time.Since(t.opened) >= t.maxDuration)
+}
 
-	return nil
+// closeFile closes the open file. The caller must hold t.mu.
+func (t *tw) closeFile() error {
+	err := t.file.Close()
+	t.file = nil
+	return err
 }
 
 func (t *tw) Close() error {
-	return nil
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.inSegment = false
+	if t.file == nil {
+		return nil
+	}
+	return t.closeFile()
 }
 
 func NewServer(logger *zap.Logger, addr string) (*Server, error) {
 	s := &Server{
 		logger:    logger,
-		tsfolders: make([]string, 0),
+		tsfolders: make([]recording, 0),
+		stop:      make(chan struct{}),
 	}
 
 	transcoder := rtmpingress.NewTranscoder(logger)
@@ -80,13 +168,28 @@ func NewServer(logger *zap.Logger, addr string) (*Server, error) {
 		Logger:      logger,
 		CheckOrigin: func(addr *rtmpingress.StreamAddr, conn *rtmpingress.Conn) bool { return true },
 		HandleStream: func(a *rtmpingress.StreamAddr, c *rtmpingress.Conn) {
-			tw := newTw()
+			s.mu.Lock()
+			maxSize, maxDuration := s.segmentSize, s.segmentDuration
+			s.mu.Unlock()
+
+			tw, err := newTw(maxSize, maxDuration)
+			if err != nil {
+				logger.Error("Failed to start recording", zap.String("key", a.Key), zap.Error(err))
+				return
+			}
+
+			s.mu.Lock()
+			s.tsfolders = append(s.tsfolders, recording{path: tw.path, started: time.Now(), active: true})
+			s.mu.Unlock()
 			go func() {
 				if err := transcoder.Transcode(c.Context(), a.URI, a.Key, "source", tw); err != nil {
 					logger.Error("transcoding", zap.Error(err))
 				}
+				if err := tw.Close(); err != nil {
+					logger.Warn("Failed to close recording", zap.String("path", tw.path), zap.Error(err))
+				}
+				s.finishRecording(tw.path)
 			}()
-			s.tsfolders = append(s.tsfolders, tw.path)
 		},
 		BaseContext: func(nc net.Conn) context.Context {
 			return context.Background()
@@ -96,12 +199,133 @@ func NewServer(logger *zap.Logger, addr string) (*Server, error) {
 	return s, nil
 }
 
+// SetRetention limits how many finished recordings are kept and for how
+// long. Zero disables either limit. Recordings still being written are never
+// pruned.
+func (s *Server) SetRetention(maxCount int, maxAge time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxCount = maxCount
+	s.maxAge = maxAge
+}
+
+// SetSegmentLimits sets when a recording moves on to a new file: once it
+// reaches maxSize bytes or has been written for maxDuration. The file is only
+// finished at a segment boundary, so it may run slightly over. With both zero
+// every segment is written to its own file.
+func (s *Server) SetSegmentLimits(maxSize int64, maxDuration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.segmentSize = maxSize
+	s.segmentDuration = maxDuration
+}
+
+// Recordings returns the retained recordings, oldest first
+func (s *Server) Recordings() []Recording {
+	s.mu.Lock()
+	folders := make([]recording, len(s.tsfolders))
+	copy(folders, s.tsfolders)
+	s.mu.Unlock()
+
+	recordings := make([]Recording, 0, len(folders))
+	for _, r := range folders {
+		recordings = append(recordings, Recording{
+			Path:    r.path,
+			Started: r.started,
+			Active:  r.active,
+			Size:    dirSize(r.path),
+		})
+	}
+	return recordings
+}
+
+// finishRecording marks the recording at path as complete and applies the
+// retention policy now that it may be pruned
+func (s *Server) finishRecording(path string) {
+	s.mu.Lock()
+	for i := range s.tsfolders {
+		if s.tsfolders[i].path == path {
+			s.tsfolders[i].active = false
+		}
+	}
+	s.mu.Unlock()
+
+	s.prune()
+}
+
+// prune removes finished recordings older than maxAge and the oldest
+// finished recordings beyond maxCount
+func (s *Server) prune() {
+	s.mu.Lock()
+	finished := 0
+	for _, r := range s.tsfolders {
+		if !r.active {
+			finished++
+		}
+	}
+
+	var kept, expired []recording
+	for _, r := range s.tsfolders {
+		tooOld := s.maxAge > 0 && time.Since(r.started) > s.maxAge
+		tooMany := s.maxCount > 0 && finished > s.maxCount
+		if r.active || (!tooOld && !tooMany) {
+			kept = append(kept, r)
+			continue
+		}
+		expired = append(expired, r)
+		finished--
+	}
+	s.tsfolders = kept
+	s.mu.Unlock()
+
+	for _, r := range expired {
+		if err := os.RemoveAll(r.path); err != nil {
+			s.logger.Warn("Failed to remove recording", zap.String("path", r.path), zap.Error(err))
+			continue
+		}
+		s.logger.Info("Pruned recording", zap.String("path", r.path), zap.Time("started", r.started))
+	}
+}
+
+// runCleaner prunes recordings periodically so maxAge applies even when no
+// new streams arrive
+func (s *Server) runCleaner() {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.prune()
+		}
+	}
+}
+
+// dirSize returns the total size of the files under dir
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
 func (s *Server) Start() error {
 	s.logger.Info("Starting RTMP server", zap.String("addr", s.server.Addr))
+	go s.runCleaner()
 	return s.server.Listen()
 }
 
 func (s *Server) Stop() error {
 	s.logger.Info("Stopping RTMP server")
+	s.stopOnce.Do(func() { close(s.stop) })
 	return s.server.Close()
 }
@@ -0,0 +1,214 @@
+package rtmp
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := NewServer(zap.NewNop(), "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	return s
+}
+
+// addRecording adds a recording directory holding size bytes, started age ago
+func addRecording(t *testing.T, s *Server, age time.Duration, active bool, size int) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "0.mp4"), make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.tsfolders = append(s.tsfolders, recording{path: dir, started: time.Now().Add(-age), active: active})
+	return dir
+}
+
+func recordingPaths(s *Server) []string {
+	var paths []string
+	for _, r := range s.Recordings() {
+		paths = append(paths, r.Path)
+	}
+	return paths
+}
+
+func TestPruneRetention(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxCount int
+		maxAge   time.Duration
+		kept     []int // Indexes of the recordings below that remain
+	}{
+		{name: "no limits", kept: []int{0, 1, 2, 3}},
+		{name: "max count keeps the newest finished", maxCount: 1, kept: []int{2, 3}},
+		{name: "max age", maxAge: 90 * time.Minute, kept: []int{2, 3}},
+		{name: "both limits", maxCount: 2, maxAge: 150 * time.Minute, kept: []int{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.SetRetention(tt.maxCount, tt.maxAge)
+
+			// Oldest first, the last still being written
+			dirs := []string{
+				addRecording(t, s, 3*time.Hour, false, 1),
+				addRecording(t, s, 2*time.Hour, false, 1),
+				addRecording(t, s, time.Hour, false, 1),
+				addRecording(t, s, 4*time.Hour, true, 1),
+			}
+			s.prune()
+
+			var want []string
+			for _, i := range tt.kept {
+				want = append(want, dirs[i])
+			}
+			if got := recordingPaths(s); !slices.Equal(got, want) {
+				t.Errorf("recordings after prune = %v, want %v", got, want)
+			}
+			for i, dir := range dirs {
+				_, err := os.Stat(dir)
+				if exists := err == nil; exists != slices.Contains(tt.kept, i) {
+					t.Errorf("recording %d exists = %v after prune", i, exists)
+				}
+			}
+		})
+	}
+}
+
+func TestFinishRecording(t *testing.T) {
+	s := newTestServer(t)
+	s.SetRetention(1, 0)
+
+	first := addRecording(t, s, 2*time.Hour, true, 1)
+	second := addRecording(t, s, time.Hour, false, 1)
+
+	// Finishing the first makes two finished recordings, one over the limit
+	s.finishRecording(first)
+	if got := recordingPaths(s); !slices.Equal(got, []string{second}) {
+		t.Errorf("recordings after finishing = %v, want only %s", got, second)
+	}
+}
+
+func TestRecordings(t *testing.T) {
+	s := newTestServer(t)
+	dir := addRecording(t, s, time.Minute, true, 100)
+	if err := os.WriteFile(filepath.Join(dir, "1.mp4"), make([]byte, 50), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	recordings := s.Recordings()
+	if len(recordings) != 1 {
+		t.Fatalf("Recordings() = %v, want one recording", recordings)
+	}
+	if r := recordings[0]; r.Path != dir || !r.Active || r.Size != 150 {
+		t.Errorf("Recordings() = %+v, want %s active with 150 bytes", r, dir)
+	}
+}
+
+// writeSegment writes one segment: its length prefixed init section, its
+// fragments and the closing Flush
+func writeSegment(t *testing.T, w *tw, init string, fragments ...string) {
+	t.Helper()
+	if n, err := w.Write(append([]byte{0, byte(len(init))}, init...)); err != nil || n != len(init)+2 {
+		t.Fatalf("Write(init) = %d, %v; want %d, nil", n, err, len(init)+2)
+	}
+	for _, f := range fragments {
+		if _, err := w.Write([]byte(f)); err != nil {
+			t.Fatalf("Write(fragment) error = %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+}
+
+// segmentFiles returns the contents of the numbered files w wrote, in order
+func segmentFiles(t *testing.T, w *tw) []string {
+	t.Helper()
+	var files []string
+	for i := 0; i < w.i; i++ {
+		data, err := os.ReadFile(filepath.Join(w.path, fmt.Sprintf("%d.mp4", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, string(data))
+	}
+	return files
+}
+
+func TestTwSegments(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxSize  int64
+		expected []string
+	}{
+		{name: "file per segment", expected: []string{"init-a1a2", "init-b1", "init-c1"}},
+		// Later init sections are dropped while appending to an open file
+		{name: "max size", maxSize: 10, expected: []string{"init-a1a2b1", "init-c1"}},
+		{name: "max size never reached", maxSize: 1 << 20, expected: []string{"init-a1a2b1c1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &tw{path: t.TempDir(), maxSize: tt.maxSize}
+			writeSegment(t, w, "init-", "a1", "a2")
+			writeSegment(t, w, "init-", "b1")
+			writeSegment(t, w, "init-", "c1")
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if got := segmentFiles(t, w); !slices.Equal(got, tt.expected) {
+				t.Errorf("segment files = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTwShortInitSection(t *testing.T) {
+	w := &tw{path: t.TempDir()}
+	if _, err := w.Write([]byte{0}); err == nil {
+		t.Fatal("Write() of a 1 byte init section = nil, want error")
+	}
+	if w.i != 0 || w.file != nil {
+		t.Error("Write() of a short init section opened a file")
+	}
+}
+
+func TestTwClose(t *testing.T) {
+	w := &tw{path: t.TempDir(), maxSize: 1 << 20}
+	writeSegment(t, w, "init-", "a1")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Errorf("Flush() after Close() error = %v", err)
+	}
+
+	// A segment after closing starts a new file rather than failing
+	writeSegment(t, w, "init-", "b1")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := segmentFiles(t, w); !slices.Equal(got, []string{"init-a1", "init-b1"}) {
+		t.Errorf("segment files = %q, want one per Close", got)
+	}
+
+	// Fragments with no open file are rejected
+	w.inSegment = true
+	if _, err := w.Write(bytes.Repeat([]byte{1}, 4)); err == nil {
+		t.Error("Write() of a fragment after Close() = nil, want error")
+	}
+}
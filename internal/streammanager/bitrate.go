@@ -0,0 +1,113 @@
+package streammanager
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseBitrate parses an ffmpeg bitrate such as "6000k", "6M" or "6000000"
+// into bits per second
+func parseBitrate(rate string) (int, error) {
+	digits, multiplier := rate, 1
+	switch {
+	case strings.HasSuffix(rate, "k"), strings.HasSuffix(rate, "K"):
+		digits, multiplier = rate[:len(rate)-1], 1000
+	case strings.HasSuffix(rate, "M"):
+		digits, multiplier = rate[:len(rate)-1], 1000000
+	}
+
+	n, err := strconv.Atoi(digits)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid bitrate %q, must be a positive number with an optional k or M suffix", rate)
+	}
+	return n * multiplier, nil
+}
+
+// formatBitrate formats bits per second the way ffmpeg accepts them
+func formatBitrate(bps int) string {
+	if bps%1000 == 0 {
+		return strconv.Itoa(bps/1000) + "k"
+	}
+	return strconv.Itoa(bps)
+}
+
+// validateBitrates checks the rate control settings parse and that the
+// minimum, target and maximum are in order. Empty values are unset.
+func validateBitrates(maxRate, target, minRate, bufSize string) error {
+	var parsed [4]int
+	for i, rate := range []struct{ name, value string }{
+		{"max bitrate", maxRate},
+		{"target bitrate", target},
+		{"min bitrate", minRate},
+		{"buffer size", bufSize},
+	} {
+		if rate.value == "" {
+			continue
+		}
+		bps, err := parseBitrate(rate.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rate.name, err)
+		}
+		parsed[i] = bps
+	}
+	maxBps, targetBps, minBps := parsed[0], parsed[1], parsed[2]
+
+	if maxBps == 0 && targetBps == 0 && (minBps > 0 || bufSize != "") {
+		return errors.New("min bitrate and buffer size require a target or max bitrate")
+	}
+	if targetBps > 0 && maxBps > 0 && targetBps > maxBps {
+		return fmt.Errorf("target bitrate %s exceeds max bitrate %s", target, maxRate)
+	}
+	// The minimum must sit below the average the encoder aims for
+	upper := targetBps
+	if upper == 0 {
+		upper = maxBps
+	}
+	if minBps > upper {
+		return fmt.Errorf("min bitrate %s exceeds the target or max bitrate", minRate)
+	}
+	return nil
+}
+
+// buildRateControlArgs returns the video rate control arguments. With only a
+// max bitrate the output is effectively constant bitrate. A target bitrate
+// averages to that rate while peaking up to the max, which defaults to the
+// target, with a buffer of two seconds at the max unless one is set. Without
+// either the encoder runs at constant quality.
+func buildRateControlArgs(cfg ffmpegArgs) []string {
+	if cfg.targetBitrate == "" {
+		if cfg.maxBitrate == "" {
+			return encoderProfileFor(cfg.encoder).qualityArgs
+		}
+		bufSize := cfg.bufSize
+		if bufSize == "" {
+			bufSize = cfg.maxBitrate
+		}
+		args := []string{"-b:v", cfg.maxBitrate, "-maxrate", cfg.maxBitrate, "-bufsize", bufSize}
+		if cfg.minBitrate != "" {
+			args = append(args, "-minrate", cfg.minBitrate)
+		}
+		return args
+	}
+
+	maxRate := cfg.maxBitrate
+	if maxRate == "" {
+		maxRate = cfg.targetBitrate
+	}
+	bufSize := cfg.bufSize
+	if bufSize == "" {
+		if bps, err := parseBitrate(maxRate); err == nil {
+			bufSize = formatBitrate(2 * bps)
+		} else {
+			bufSize = maxRate
+		}
+	}
+
+	args := []string{"-b:v", cfg.targetBitrate, "-maxrate", maxRate, "-bufsize", bufSize}
+	if cfg.minBitrate != "" {
+		args = append(args, "-minrate", cfg.minBitrate)
+	}
+	return args
+}
@@ -0,0 +1,84 @@
+package streammanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildRateControlArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      ffmpegArgs
+		expected []string
+	}{
+		{
+			name:     "constant quality",
+			cfg:      ffmpegArgs{},
+			expected: []string{"-crf", "18"},
+		},
+		{
+			name:     "max bitrate only is constant bitrate",
+			cfg:      ffmpegArgs{maxBitrate: "6000k"},
+			expected: []string{"-b:v", "6000k", "-maxrate", "6000k", "-bufsize", "6000k"},
+		},
+		{
+			name:     "target bitrate with a higher peak",
+			cfg:      ffmpegArgs{targetBitrate: "4000k", maxBitrate: "6000k"},
+			expected: []string{"-b:v", "4000k", "-maxrate", "6000k", "-bufsize", "12000k"},
+		},
+		{
+			name:     "target bitrate only",
+			cfg:      ffmpegArgs{targetBitrate: "4M"},
+			expected: []string{"-b:v", "4M", "-maxrate", "4M", "-bufsize", "8000k"},
+		},
+		{
+			name: "full VBV band",
+			cfg: ffmpegArgs{
+				minBitrate:    "2000k",
+				targetBitrate: "4000k",
+				maxBitrate:    "6000k",
+				bufSize:       "3000k",
+			},
+			expected: []string{"-b:v", "4000k", "-maxrate", "6000k", "-bufsize", "3000k", "-minrate", "2000k"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildRateControlArgs(tt.cfg); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("buildRateControlArgs() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateBitrates(t *testing.T) {
+	tests := []struct {
+		name    string
+		max     string
+		target  string
+		min     string
+		bufSize string
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "max only", max: "6000k"},
+		{name: "band", max: "6M", target: "4000k", min: "2000k", bufSize: "12000k"},
+		{name: "plain bits per second", target: "4000000"},
+		{name: "unparsable", max: "fast", wantErr: true},
+		{name: "zero", target: "0k", wantErr: true},
+		{name: "target above max", max: "4000k", target: "6000k", wantErr: true},
+		{name: "min above target", target: "4000k", min: "5000k", wantErr: true},
+		{name: "min without a rate", min: "2000k", wantErr: true},
+		{name: "buffer without a rate", bufSize: "2000k", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBitrates(tt.max, tt.target, tt.min, tt.bufSize)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateBitrates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
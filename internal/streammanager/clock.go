@@ -0,0 +1,17 @@
+package streammanager
+
+import "time"
+
+// clock is the source of time for the StreamManager's timing logic, so tests
+// can control time instead of sleeping
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the wall clock used outside of tests
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
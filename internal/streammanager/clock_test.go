@@ -0,0 +1,81 @@
+package streammanager
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when Advance is called
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d, firing any After channels that are due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+func TestFakeClockAfter(t *testing.T) {
+	c := newFakeClock()
+	ch := c.After(5 * time.Second)
+
+	c.Advance(4 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("After() fired before its duration elapsed")
+	default:
+	}
+
+	c.Advance(time.Second)
+	select {
+	case got := <-ch:
+		if want := c.Now(); !got.Equal(want) {
+			t.Errorf("After() fired with %v, want %v", got, want)
+		}
+	default:
+		t.Fatal("After() did not fire once its duration elapsed")
+	}
+}
@@ -0,0 +1,79 @@
+package streammanager
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	demuxersMu sync.Mutex
+	// demuxerNames caches the demuxers of the ffmpeg binary once listed
+	demuxerNames []string
+)
+
+// validateInputFormat checks that format names a demuxer ffmpeg has. An
+// empty format lets ffmpeg detect it.
+func validateInputFormat(ctx context.Context, format string) error {
+	if format == "" {
+		return nil
+	}
+
+	names, err := listDemuxers(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(names, format) {
+		return fmt.Errorf("unsupported input format %q, see ffmpeg -demuxers", format)
+	}
+	return nil
+}
+
+// listDemuxers returns the demuxer names ffmpeg accepts for -f, listing them
+// on first use
+func listDemuxers(ctx context.Context) ([]string, error) {
+	demuxersMu.Lock()
+	defer demuxersMu.Unlock()
+
+	if demuxerNames != nil {
+		return demuxerNames, nil
+	}
+
+	output, err := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-demuxers").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ffmpeg demuxers: %w", err)
+	}
+	demuxerNames = parseDemuxers(string(output))
+	return demuxerNames, nil
+}
+
+// parseDemuxers extracts the names from ffmpeg -demuxers output. Entries
+// with several names, such as "mov,mp4,m4a", accept each of them.
+func parseDemuxers(output string) []string {
+	var names []string
+	listing := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "--" {
+			listing = true
+			continue
+		}
+		if !listing {
+			continue
+		}
+
+		// Each line is the capability flags, the names and a description
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		names = append(names, strings.Split(fields[1], ",")...)
+	}
+	return names
+}
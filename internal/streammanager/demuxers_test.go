@@ -0,0 +1,27 @@
+package streammanager
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseDemuxers(t *testing.T) {
+	output := `File formats:
+ D. = Demuxing supported
+ .E = Muxing supported
+ --
+ D  aac             raw ADTS AAC (Advanced Audio Coding)
+ D  h264            raw H.264 video
+ D  mov,mp4,m4a,3gp,3g2,mj2 QuickTime / MOV
+ D  v4l2            Video4Linux2 device grab
+`
+	names := parseDemuxers(output)
+	for _, want := range []string{"aac", "h264", "mov", "mp4", "m4a", "v4l2"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("parseDemuxers() = %v, missing %q", names, want)
+		}
+	}
+	if slices.Contains(names, "=") || slices.Contains(names, "Demuxing") {
+		t.Errorf("parseDemuxers() = %v, includes the legend", names)
+	}
+}
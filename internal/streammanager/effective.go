@@ -0,0 +1,79 @@
+package streammanager
+
+import (
+	"context"
+	"slices"
+)
+
+// EffectiveSettings are the settings a queued entry will play with once
+// server defaults and config are applied to its own options
+type EffectiveSettings struct {
+	ID               string          `json:"id"`
+	File             string          `json:"file"`
+	Overlay          OverlaySettings `json:"overlay"`
+	Filtered         bool            `json:"filtered"` // Whether filters or overlays apply on top of the re-encode
+	Encoder          string          `json:"encoder"`
+	Preset           string          `json:"preset"`
+	RateControl      []string        `json:"rateControl"` // Bitrate or quality flags passed to the encoder
+	KeyframeInterval string          `json:"keyframeInterval,omitempty"`
+	StartTimestamp   string          `json:"startTimestamp,omitempty"`
+	EndTimestamp     string          `json:"endTimestamp,omitempty"`
+	FadeIn           float64         `json:"fadeIn,omitempty"`
+	FadeOut          float64         `json:"fadeOut,omitempty"`
+	SubtitleFile     string          `json:"subtitleFile,omitempty"`
+	SubtitleEncoding string          `json:"subtitleEncoding,omitempty"` // Encoding converted from, empty for UTF-8
+	Repeat           int             `json:"repeat"`
+	LogLevel         string          `json:"logLevel"`
+	VideoFilter      string          `json:"videoFilter"`
+	AudioFilter      string          `json:"audioFilter"`
+	Args             []string        `json:"args"`
+}
+
+// EffectiveSettings resolves the settings the queued entry will play with,
+// through the same merge the queue processor applies. It reports false when
+// no queued entry has the id.
+func (s *StreamManager) EffectiveSettings(ctx context.Context, id string) (EffectiveSettings, bool) {
+	s.mu.RLock()
+	i := slices.IndexFunc(s.queue, func(e entry) bool { return e.ID == id })
+	if i < 0 {
+		s.mu.RUnlock()
+		return EffectiveSettings{}, false
+	}
+	e := s.queue[i]
+	password, streamKey := s.config.Password, s.config.StreamKey
+	s.mu.RUnlock()
+
+	cfg := s.resolvedPreprocessingArgs(e, probeFile(ctx, s.logger, e.File))
+	args := buildPreprocessingArgs(cfg)
+	for i, arg := range args {
+		args[i] = redactSecrets(arg, password, streamKey)
+	}
+
+	profile := encoderProfileFor(cfg.encoder)
+	logLevel := cfg.logLevel
+	if logLevel == "" {
+		logLevel = "error"
+	}
+
+	return EffectiveSettings{
+		ID:               e.ID,
+		File:             e.File,
+		Overlay:          cfg.overlay,
+		Filtered:         filterCount(cfg) > 0,
+		Encoder:          profile.encoder,
+		Preset:           profile.preset(cfg.preset),
+		RateControl:      buildRateControlArgs(cfg),
+		KeyframeInterval: cfg.keyframeInterval,
+		StartTimestamp:   cfg.startTimestamp,
+		EndTimestamp:     cfg.endTimestamp,
+		FadeIn:           cfg.fadeIn,
+		FadeOut:          cfg.fadeOut,
+		SubtitleFile:     cfg.subtitleFile,
+		SubtitleEncoding: cfg.subtitleCharenc,
+		Repeat:           max(e.Repeat, 1),
+		LogLevel:         logLevel,
+		VideoFilter:      buildVideoFilter(cfg),
+		AudioFilter:      buildAudioFilter(cfg),
+		Args:             args,
+	}, true
+}
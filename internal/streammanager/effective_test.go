@@ -0,0 +1,74 @@
+package streammanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEffectiveSettings(t *testing.T) {
+	sm := newTestStreamManager(t)
+	sm.config = Config{Encoder: "libx265", MaxBitrate: "6000k", TargetBitrate: "4000k", KeyframeInterval: "60"}
+	if err := sm.SetDefaultOverlay(OverlaySettings{ShowFilename: true, Position: "top-left", FontSize: 32}); err != nil {
+		t.Fatal(err)
+	}
+
+	subtitles := filepath.Join(t.TempDir(), "subs.srt")
+	if err := os.WriteFile(subtitles, []byte("1\n00:00:01,000 --> 00:00:02,000\nCaf\xe9\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	first := sm.Enqueue("/media/first.mp4", EntryOptions{})
+	id := sm.Enqueue("/media/second.mp4", EntryOptions{
+		Overlay:        sm.EntryOverlay(&OverlaySettings{ShowFilename: true, Position: "bottom-right"}),
+		StartTimestamp: "10",
+		SubtitleFile:   subtitles,
+	})
+
+	if _, ok := sm.EffectiveSettings(context.Background(), "missing"); ok {
+		t.Error("EffectiveSettings() reported an entry for an unknown id")
+	}
+
+	got, ok := sm.EffectiveSettings(context.Background(), id)
+	if !ok {
+		t.Fatalf("EffectiveSettings(%q) reported no entry", id)
+	}
+
+	want := OverlaySettings{ShowFilename: true, Position: "bottom-right", FontSize: 32}
+	if got.Overlay != want {
+		t.Errorf("Overlay = %+v, want %+v", got.Overlay, want)
+	}
+	if got.Encoder != "libx265" || got.Preset != "ultrafast" {
+		t.Errorf("Encoder, Preset = %q, %q; want libx265, ultrafast", got.Encoder, got.Preset)
+	}
+	if !got.Filtered {
+		t.Error("Filtered = false for an entry with an overlay and subtitles")
+	}
+	if wantRate := []string{"-b:v", "4000k", "-maxrate", "6000k", "-bufsize", "12000k"}; !slices.Equal(got.RateControl, wantRate) {
+		t.Errorf("RateControl = %v, want %v", got.RateControl, wantRate)
+	}
+	if got.StartTimestamp != "10" || got.KeyframeInterval != "60" || got.Repeat != 1 {
+		t.Errorf("StartTimestamp, KeyframeInterval, Repeat = %q, %q, %d; want 10, 60, 1",
+			got.StartTimestamp, got.KeyframeInterval, got.Repeat)
+	}
+	if got.SubtitleEncoding != encodingLatin1 {
+		t.Errorf("SubtitleEncoding = %q, want %q", got.SubtitleEncoding, encodingLatin1)
+	}
+	if !slices.Contains(got.Args, "/media/second.mp4") || !slices.Contains(got.Args, "libx265") {
+		t.Errorf("Args = %v, want the source and encoder", got.Args)
+	}
+
+	plain, ok := sm.EffectiveSettings(context.Background(), first)
+	if !ok {
+		t.Fatalf("EffectiveSettings(%q) reported no entry", first)
+	}
+	if plain.Filtered {
+		t.Errorf("Filtered = true for an entry without filters, video filter %q", plain.VideoFilter)
+	}
+
+	if got := len(sm.Queue()); got != 2 {
+		t.Errorf("queue length after EffectiveSettings() = %d, want 2", got)
+	}
+}
@@ -0,0 +1,86 @@
+package streammanager
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrEncoderUnavailable is returned by CheckEncoderAvailable when ffmpeg
+// lists its encoders and the requested one is not among them
+var ErrEncoderUnavailable = errors.New("encoder is not available in this ffmpeg build")
+
+var (
+	encodersMu sync.Mutex
+	// encoderNames caches the encoders of the ffmpeg binary once listed
+	encoderNames []string
+)
+
+// CheckEncoderAvailable checks that encoder names an encoder ffmpeg was built
+// with, so a missing one is reported before the stream starts rather than
+// when the first file is preprocessed. An empty encoder uses the default.
+// It returns ErrEncoderUnavailable only when ffmpeg listed its encoders
+// without it; failing to list them is reported as a different error.
+func CheckEncoderAvailable(ctx context.Context, encoder string) error {
+	if encoder == "" {
+		return nil
+	}
+
+	names, err := listEncoders(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(names, encoder) {
+		return fmt.Errorf("%w: %q, see ffmpeg -encoders", ErrEncoderUnavailable, encoder)
+	}
+	return nil
+}
+
+// listEncoders returns the encoder names ffmpeg accepts for -c, listing them
+// on first use
+func listEncoders(ctx context.Context) ([]string, error) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	if encoderNames != nil {
+		return encoderNames, nil
+	}
+
+	output, err := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-encoders").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ffmpeg encoders: %w", err)
+	}
+	encoderNames = parseEncoders(string(output))
+	return encoderNames, nil
+}
+
+// parseEncoders extracts the names from ffmpeg -encoders output
+func parseEncoders(output string) []string {
+	var names []string
+	listing := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "------" {
+			listing = true
+			continue
+		}
+		if !listing {
+			continue
+		}
+
+		// Each line is the capability flags, the name and a description
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		names = append(names, fields[1])
+	}
+	return names
+}
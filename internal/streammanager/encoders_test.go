@@ -0,0 +1,71 @@
+package streammanager
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+const encodersOutput = `Encoders:
+ V..... = Video
+ A..... = Audio
+ S..... = Subtitle
+ .F.... = Frame-level multithreading
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+ V....D h264_nvenc           NVIDIA NVENC H.264 encoder (codec h264)
+ A....D aac                  AAC (Advanced Audio Coding)
+`
+
+func TestParseEncoders(t *testing.T) {
+	names := parseEncoders(encodersOutput)
+	if want := []string{"libx264", "h264_nvenc", "aac"}; !slices.Equal(names, want) {
+		t.Errorf("parseEncoders() = %v, want %v", names, want)
+	}
+}
+
+func TestCheckEncoderAvailable(t *testing.T) {
+	encodersMu.Lock()
+	encoderNames = parseEncoders(encodersOutput)
+	encodersMu.Unlock()
+	t.Cleanup(func() {
+		encodersMu.Lock()
+		encoderNames = nil
+		encodersMu.Unlock()
+	})
+
+	tests := []struct {
+		encoder string
+		wantErr bool
+	}{
+		{encoder: ""},
+		{encoder: "libx264"},
+		{encoder: "h264_nvenc"},
+		{encoder: "libfake264", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoder, func(t *testing.T) {
+			err := CheckEncoderAvailable(context.Background(), tt.encoder)
+			if errors.Is(err, ErrEncoderUnavailable) != tt.wantErr || (err != nil) != tt.wantErr {
+				t.Errorf("CheckEncoderAvailable(%q) error = %v, wantErr %v", tt.encoder, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckEncoderListingFails(t *testing.T) {
+	withFakeFFmpeg(t, "exit 1\n")
+	t.Cleanup(func() {
+		encodersMu.Lock()
+		encoderNames = nil
+		encodersMu.Unlock()
+	})
+
+	// Not knowing the encoders is not the same as the encoder being missing
+	err := CheckEncoderAvailable(context.Background(), "libx264")
+	if err == nil || errors.Is(err, ErrEncoderUnavailable) {
+		t.Errorf("CheckEncoderAvailable() error = %v, want a listing failure", err)
+	}
+}
@@ -0,0 +1,167 @@
+package streammanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Actions taken once the queue has drained
+const (
+	EndOfQueueIdle  = "idle"  // Wait on the empty FIFO for more entries
+	EndOfQueueStop  = "stop"  // Finish the stream gracefully
+	EndOfQueueLoop  = "loop"  // Requeue everything played since the last drain
+	EndOfQueueSlate = "slate" // Show Config.SlateImage until more entries arrive
+)
+
+var endOfQueueActions = []string{EndOfQueueIdle, EndOfQueueStop, EndOfQueueLoop, EndOfQueueSlate}
+
+// validateEndOfQueue checks the end of queue action and that slate has an image
+func validateEndOfQueue(action, slateImage string) error {
+	if action != "" && !slices.Contains(endOfQueueActions, action) {
+		return fmt.Errorf("unsupported end of queue action %q, must be one of %s",
+			action, strings.Join(endOfQueueActions, ", "))
+	}
+	if action == EndOfQueueSlate {
+		if slateImage == "" {
+			return errors.New("end of queue action slate requires a slate image")
+		}
+		if err := validateImageFile(slateImage); err != nil {
+			return fmt.Errorf("invalid slate image: %w", err)
+		}
+	}
+	return nil
+}
+
+// endOfQueueAction returns the configured action with the default applied.
+// The caller must hold s.mu.
+func (s *StreamManager) endOfQueueAction() string {
+	if s.config.EndOfQueueAction == "" {
+		return EndOfQueueIdle
+	}
+	return s.config.EndOfQueueAction
+}
+
+// endOfQueue applies the end of queue action if the queue has drained. It
+// reports whether the queue processor should stop.
+func (s *StreamManager) endOfQueue(ctx context.Context) bool {
+	s.mu.Lock()
+	if ctx.Err() != nil || s.held || len(s.queue) > 0 {
+		s.mu.Unlock()
+		return false
+	}
+
+	action := s.endOfQueueAction()
+	if action == EndOfQueueLoop {
+		for _, e := range s.played {
+			e.ID = s.newEntryID()
+			e.State, e.StateSince = stateQueued, s.clock.Now()
+			s.queue = append(s.queue, e)
+		}
+		s.played = nil
+		s.notifyQueue()
+	}
+	s.mu.Unlock()
+
+	switch action {
+	case EndOfQueueStop:
+		s.logger.Info("Queue drained, stopping stream")
+		return true
+	case EndOfQueueLoop:
+		s.logger.Info("Queue drained, looping playlist")
+	case EndOfQueueSlate:
+		s.logger.Info("Queue drained, showing slate")
+		s.showSlate(ctx)
+	}
+	return false
+}
+
+// showSlate streams the slate image until an entry is enqueued or ctx is done
+func (s *StreamManager) showSlate(ctx context.Context) {
+	if s.slateUntil(ctx, s.queueNotify) {
+		// Hand the signal back to the queue processor
+		s.notifyQueue()
+	}
+}
+
+// slateUntil streams the slate image until wake is signalled or ctx is done.
+// It reports whether wake ended it.
+func (s *StreamManager) slateUntil(ctx context.Context, wake <-chan struct{}) bool {
+	s.mu.Lock()
+	s.showingSlate = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.showingSlate = false
+		s.mu.Unlock()
+	}()
+
+	slateCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.writeSlate(slateCtx)
+	}()
+
+	select {
+	case <-wake:
+		cancel()
+		<-done
+		return true
+	case <-ctx.Done():
+		cancel()
+		<-done
+	case err := <-done:
+		if err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Error("Slate stopped", zap.Error(err))
+		}
+	}
+	return false
+}
+
+// writeSlate encodes the slate image with silent audio into the FIFO
+func (s *StreamManager) writeSlate(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg", s.slateArgs()...)
+	cmd.Stdout = s.fifo
+
+	var stderrBuf strings.Builder
+	cmd.Stderr = &stderrBuf
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderrBuf.String()); msg != "" {
+			return fmt.Errorf("ffmpeg failed: %w\nFFmpeg stderr: %s", err, msg)
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return nil
+}
+
+// slateArgs builds the slate encode from the current config
+func (s *StreamManager) slateArgs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return buildSlateArgs(ffmpegArgs{
+		imageFile:        s.config.SlateImage,
+		logLevel:         processLogLevel(s.config.PreprocessLogLevel, s.config.LogLevel),
+		encoder:          s.config.Encoder,
+		preset:           s.config.Preset,
+		keyframeInterval: s.config.KeyframeInterval,
+		bFrames:          s.config.BFrames,
+		refFrames:        s.config.RefFrames,
+		pixelFormat:      s.config.PixelFormat,
+		sampleRate:       s.config.AudioSampleRate,
+		outputSize:       s.config.OutputSize,
+		frameRate:        s.config.OutputFrameRate,
+	})
+}
@@ -0,0 +1,86 @@
+package streammanager
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateEndOfQueue(t *testing.T) {
+	slate := filepath.Join(t.TempDir(), "slate.png")
+	if err := os.WriteFile(slate, []byte("png"), 0o644); err != nil {
+		t.Fatalf("failed to write slate image: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		action     string
+		slateImage string
+		expectErr  bool
+	}{
+		{name: "unset"},
+		{name: "stop", action: EndOfQueueStop},
+		{name: "loop", action: EndOfQueueLoop},
+		{name: "slate with image", action: EndOfQueueSlate, slateImage: slate},
+		{name: "slate without image", action: EndOfQueueSlate, expectErr: true},
+		{name: "slate with missing image", action: EndOfQueueSlate, slateImage: "/nonexistent.png", expectErr: true},
+		{name: "unknown", action: "shuffle", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEndOfQueue(tt.action, tt.slateImage)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("validateEndOfQueue(%q, %q) error = %v, expectErr %v", tt.action, tt.slateImage, err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestEndOfQueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("idle", func(t *testing.T) {
+		sm := newTestStreamManager(t)
+		if sm.endOfQueue(ctx) {
+			t.Error("endOfQueue() = true with the idle action, want false")
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		sm := newTestStreamManager(t)
+		sm.config.EndOfQueueAction = EndOfQueueStop
+		if !sm.endOfQueue(ctx) {
+			t.Error("endOfQueue() = false with the stop action, want true")
+		}
+
+		sm.Enqueue("a.mp4", EntryOptions{})
+		if sm.endOfQueue(ctx) {
+			t.Error("endOfQueue() = true with entries queued, want false")
+		}
+	})
+
+	t.Run("loop", func(t *testing.T) {
+		sm := newTestStreamManager(t)
+		sm.ctx = ctx
+		sm.config.EndOfQueueAction = EndOfQueueLoop
+
+		sm.finishEntry(entry{ID: "1", File: "a.mp4"}, nil)
+		sm.finishEntry(entry{ID: "2", File: "b.mp4"}, context.Canceled)
+		sm.finishEntry(entry{ID: "3", File: "c.mp4"}, errors.New("boom"))
+
+		if sm.endOfQueue(ctx) {
+			t.Fatal("endOfQueue() = true with the loop action, want false")
+		}
+
+		queue := sm.Queue()
+		if len(queue) != 2 || queue[0].File != "a.mp4" || queue[1].File != "b.mp4" {
+			t.Fatalf("queue after loop = %v, want a.mp4 and b.mp4 requeued", queue)
+		}
+		if queue[0].ID == "1" || queue[0].ID == queue[1].ID {
+			t.Errorf("requeued entries have ids %q and %q, want fresh unique ids", queue[0].ID, queue[1].ID)
+		}
+	})
+}
@@ -1,25 +1,272 @@
 package streammanager
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+)
+
+var (
+	encodeSemMu sync.Mutex
+	encodeSem   = semaphore.NewWeighted(int64(runtime.NumCPU()))
 )
 
+// SetMaxConcurrentEncodes limits how many preprocessing ffmpeg processes, each
+// of which re-encodes, may run at once across all StreamManagers in the
+// process. Values below 1 are treated as 1.
+func SetMaxConcurrentEncodes(n int) {
+	n = max(n, 1)
+
+	encodeSemMu.Lock()
+	defer encodeSemMu.Unlock()
+	encodeSem = semaphore.NewWeighted(int64(n))
+}
+
+// acquireEncodeSlot blocks until a re-encode slot is free and returns a
+// function that releases it
+func acquireEncodeSlot(ctx context.Context) (func(), error) {
+	encodeSemMu.Lock()
+	sem := encodeSem
+	encodeSemMu.Unlock()
+
+	if err := sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	return func() { sem.Release(1) }, nil
+}
+
+var (
+	// h264Profiles are the H264 profiles that can be requested for output
+	h264Profiles = []string{"baseline", "main", "high"}
+	// h264Levels are the H264 levels that can be requested for output
+	h264Levels = []string{"3.0", "3.1", "3.2", "4.0", "4.1", "4.2", "5.0", "5.1", "5.2"}
+	// ffmpegLogLevels are the values accepted by ffmpeg's -loglevel
+	ffmpegLogLevels = []string{"quiet", "panic", "fatal", "error", "warning", "info", "verbose", "debug", "trace"}
+	// pixelFormats are the output pixel formats that can be requested
+	pixelFormats = []string{"yuv420p", "yuv422p", "yuv444p", "yuv420p10le", "yuv422p10le", "yuv444p10le"}
+	// audioSampleRates are the AAC sample rates that can be requested for output
+	audioSampleRates = []int{8000, 11025, 16000, 22050, 24000, 32000, 44100, 48000, 88200, 96000}
+)
+
+// validateFFmpegLogLevel checks that level is accepted by ffmpeg's -loglevel.
+// An empty value uses error.
+func validateFFmpegLogLevel(level string) error {
+	if level != "" && !slices.Contains(ffmpegLogLevels, level) {
+		return fmt.Errorf("unsupported ffmpeg log level %q, must be one of %s", level, strings.Join(ffmpegLogLevels, ", "))
+	}
+	return nil
+}
+
+// processLogLevel returns the -loglevel for one ffmpeg process: its own
+// override when set, otherwise the level shared by both processes
+func processLogLevel(override, shared string) string {
+	if override != "" {
+		return override
+	}
+	return shared
+}
+
+// defaultPixelFormat is the output pixel format with the broadest player support
+const defaultPixelFormat = "yuv420p"
+
+// validatePixelFormat checks that the output pixel format is a known value.
+// An empty value uses defaultPixelFormat.
+func validatePixelFormat(pixelFormat string) error {
+	if pixelFormat != "" && !slices.Contains(pixelFormats, pixelFormat) {
+		return fmt.Errorf("unsupported pixel format %q, must be one of %s", pixelFormat, strings.Join(pixelFormats, ", "))
+	}
+	return nil
+}
+
+// defaultSilenceSampleRate is the sample rate of generated silent audio when
+// no output sample rate is configured
+const defaultSilenceSampleRate = 44100
+
+// silentAudioSource generates stereo silence at the output sample rate
+func silentAudioSource(sampleRate int) string {
+	if sampleRate == 0 {
+		sampleRate = defaultSilenceSampleRate
+	}
+	return fmt.Sprintf("anullsrc=channel_layout=stereo:sample_rate=%d", sampleRate)
+}
+
+// validateAudioSampleRate checks that the output sample rate is one AAC
+// supports. Zero keeps each source's own rate.
+func validateAudioSampleRate(rate int) error {
+	if rate != 0 && !slices.Contains(audioSampleRates, rate) {
+		return fmt.Errorf("unsupported audio sample rate %d, must be one of %s", rate, joinInts(audioSampleRates))
+	}
+	return nil
+}
+
+// parseOutputSize parses an output size written as WxH
+func parseOutputSize(size string) (int, int, error) {
+	w, h, ok := strings.Cut(size, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("output size %q must be written as WxH, e.g. 1280x720", size)
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid output width %q", w)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid output height %q", h)
+	}
+	return width, height, nil
+}
+
+// validateOutputFormat checks the output size and frame rate every entry is
+// normalized to. Empty values keep each source's own.
+func validateOutputFormat(size string, frameRate int) error {
+	if frameRate < 0 {
+		return errors.New("output frame rate must not be negative")
+	}
+	if size == "" {
+		return nil
+	}
+	width, height, err := parseOutputSize(size)
+	if err != nil {
+		return err
+	}
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("output size %q must be positive", size)
+	}
+	return validateOutputDimensions(width, height)
+}
+
+// joinInts formats values as a comma-separated list
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// validateProfileLevel checks that the H264 profile and level are known
+// values. Empty values leave the encoder default.
+func validateProfileLevel(profile, level string) error {
+	if profile != "" && !slices.Contains(h264Profiles, profile) {
+		return fmt.Errorf("unsupported H264 profile %q, must be one of %s", profile, strings.Join(h264Profiles, ", "))
+	}
+	if level != "" && !slices.Contains(h264Levels, level) {
+		return fmt.Errorf("unsupported H264 level %q, must be one of %s", level, strings.Join(h264Levels, ", "))
+	}
+	return nil
+}
+
+// validateEncoderConflicts checks for encoder settings that contradict each
+// other or the preprocessing pipeline
+func validateEncoderConflicts(encoder, profile, pixelFormat string) error {
+	// Preprocessing always re-encodes to apply filters and normalize streams
+	if encoder == "copy" {
+		return errors.New("encoder copy conflicts with preprocessing, which always re-encodes; use a video encoder such as libx264")
+	}
+	// The supported profiles are all 8-bit 4:2:0 only
+	if profile != "" && pixelFormat != "" && pixelFormat != defaultPixelFormat {
+		return fmt.Errorf("H264 profile %q conflicts with pixel format %q, the profile only supports %s",
+			profile, pixelFormat, defaultPixelFormat)
+	}
+	return nil
+}
+
+// maxReferenceFrames is the most B-frames and reference frames H264 allows
+const maxReferenceFrames = 16
+
+// validateFrameSettings checks the B-frame and reference frame counts. Empty
+// values leave the encoder's defaults.
+func validateFrameSettings(bFrames, refFrames string) error {
+	if bFrames != "" {
+		n, err := strconv.Atoi(bFrames)
+		if err != nil || n < 0 || n > maxReferenceFrames {
+			return fmt.Errorf("invalid B-frames %q, must be between 0 and %d", bFrames, maxReferenceFrames)
+		}
+	}
+	if refFrames != "" {
+		n, err := strconv.Atoi(refFrames)
+		if err != nil || n < 1 || n > maxReferenceFrames {
+			return fmt.Errorf("invalid reference frames %q, must be between 1 and %d", refFrames, maxReferenceFrames)
+		}
+	}
+	return nil
+}
+
+// buildFrameArgs returns the B-frame and reference frame arguments. -bf and
+// -refs are generic codec options, so libx264 and the nvenc and qsv hardware
+// encoders all take them as they are.
+func buildFrameArgs(cfg ffmpegArgs) []string {
+	var args []string
+	if cfg.bFrames != "" {
+		args = append(args, "-bf", cfg.bFrames)
+	}
+	if cfg.refFrames != "" {
+		args = append(args, "-refs", cfg.refFrames)
+	}
+	return args
+}
+
 type ffmpegArgs struct {
-	logLevel         string
-	encoder          string
-	preset           string
-	source           string
-	overlay          OverlaySettings
-	startTimestamp   string
-	subtitleFile     string
-	fifoPath         string
-	destination      string
-	username         string
-	password         string
-	keyframeInterval string
-	maxBitrate       string
-	probeInfo        fileProbeInfo
+	logLevel           string
+	encoder            string
+	preset             string
+	source             string
+	inputFormat        string
+	overlay            OverlaySettings
+	startTimestamp     string
+	endTimestamp       string
+	subtitleFile       string
+	subtitleSize       string // original_size for ASS subtitles, e.g. "1920x1080"
+	subtitleCharenc    string // Character encoding to convert subtitles from, empty for UTF-8
+	imageFile          string
+	mute               bool
+	fadeIn             float64
+	fadeOut            float64
+	volume             float64 // Audio amplitude multiplier, 0 or 1 leaves it unchanged
+	fifoPath           string
+	destination        string
+	destinations       []StreamDestination
+	recordPath         string
+	streamKey          string
+	connectTimeout     int
+	noRealtime         bool
+	timestampMode      string
+	maxMuxingQueueSize int
+	username           string
+	password           string
+	keyframeInterval   string
+	bFrames            string
+	refFrames          string
+	noSceneCut         bool
+	fastStart          int  // Seconds with a forced keyframe every second
+	autoRotate         bool // Turn the picture upright from the probed rotation
+	profile            string
+	level              string
+	pixelFormat        string
+	maxBitrate         string
+	targetBitrate      string
+	minBitrate         string
+	bufSize            string
+	sampleRate         int
+	normalizeAudio     bool // Apply loudnormFilter to the source's audio
+	silentAudio        bool // Generate silence for entries without audio
+	legacyFPSMode      bool // ffmpeg predates -fps_mode
+	liveTextFile       string
+	liveTextPosition   string
+	outputSize         string // Scale and pad every entry to WxH
+	frameRate          int
+	streamTitle        string
+	metadata           map[string]string
+	probeInfo          fileProbeInfo
 }
 
 // buildFFmpegArgs builds ffmpeg arguments for both preprocessing and streaming
@@ -35,15 +282,45 @@ func buildFFmpegArgs(cfg ffmpegArgs) []string {
 
 // buildPreprocessingArgs builds ffmpeg arguments for preprocessing (writeToFIFO)
 func buildPreprocessingArgs(cfg ffmpegArgs) []string {
+	profile := encoderProfileFor(cfg.encoder)
 	args := []string{"-hide_banner"}
+	args = append(args, profile.initArgs...)
+
+	// Loop a still image, or black frames, as the video for an audio-only source
+	if cfg.imageFile != "" {
+		args = append(args, "-loop", "1", "-i", cfg.imageFile)
+	} else if cfg.probeInfo.audioOnly() {
+		args = append(args, "-f", "lavfi", "-i", blackVideoSource(cfg))
+	}
 
 	// Add start timestamp if provided
 	if cfg.startTimestamp != "" {
 		args = append(args, "-ss", cfg.startTimestamp)
 	}
 
+	// Stop at the end timestamp. -ss resets the input's timestamps, so the
+	// length to read is measured from the start.
+	if length, ok := trimLength(cfg.startTimestamp, cfg.endTimestamp); ok {
+		args = append(args, "-t", strconv.FormatFloat(length, 'f', -1, 64))
+	}
+
+	// Name the demuxer for sources ffmpeg cannot detect on its own
+	if cfg.inputFormat != "" {
+		args = append(args, "-f", cfg.inputFormat)
+	}
+
+	// Rotate explicitly, so ffmpeg must not also apply the display matrix
+	if rotationFilter(cfg) != "" {
+		args = append(args, "-noautorotate")
+	}
+
 	args = append(args, "-i", cfg.source)
 
+	silence := needsSilentAudio(cfg)
+	if silence {
+		args = append(args, "-f", "lavfi", "-i", silentAudioSource(cfg.sampleRate))
+	}
+
 	// Add subtitle input if provided
 	if cfg.subtitleFile != "" {
 		args = append(args, "-i", cfg.subtitleFile)
@@ -56,61 +333,229 @@ func buildPreprocessingArgs(cfg ffmpegArgs) []string {
 	}
 	args = append(args, "-loglevel", logLevel)
 
+	// Take the picture from the generated input, ignoring any cover art
+	// embedded in the audio, or the sound from the generated silence
+	if hasStillVideo(cfg) || silence {
+		args = append(args, "-map", "0:v:0", "-map", "1:a:0")
+	}
+
 	// Always re-encode to ensure compatibility and handle all processing here
 	// This includes overlays, subtitles, codec compatibility, and stream standardization
 
 	// Build and apply video filter if needed
 	if videoFilter := buildVideoFilter(cfg); videoFilter != "" {
-		args = append(args, "-vf", videoFilter, "-fps_mode", "vfr")
+		args = append(args, "-vf", videoFilter)
+		args = append(args, fpsModeArgs(cfg.legacyFPSMode)...)
 	}
 
 	// Always encode video with consistent settings for downstream compatibility
-	encoder, preset := getEncoderAndPreset(cfg.encoder, cfg.preset, "ultrafast")
-	args = append(args, "-c:v", encoder, "-preset", preset)
+	args = append(args, profile.encoderArgs(cfg.preset)...)
+
+	if cfg.profile != "" {
+		args = append(args, "-profile:v", cfg.profile)
+	}
+	if cfg.level != "" {
+		args = append(args, "-level:v", cfg.level)
+	}
 
 	// Add keyframe settings if specified
 	if cfg.keyframeInterval != "" {
 		args = append(args, "-g", cfg.keyframeInterval, "-keyint_min", cfg.keyframeInterval)
 	}
 
-	// Add bitrate settings if specified
-	if cfg.maxBitrate != "" {
-		args = append(args, "-b:v", cfg.maxBitrate, "-maxrate", cfg.maxBitrate, "-bufsize", cfg.maxBitrate)
-	} else {
-		args = append(args, "-crf", "18")
+	// Only place keyframes on the GOP boundary for strict-GOP destinations
+	if cfg.noSceneCut {
+		args = append(args, "-sc_threshold", "0")
+	}
+
+	// Fewer B-frames lower latency, fewer reference frames lower decode cost
+	args = append(args, buildFrameArgs(cfg)...)
+
+	// Viewers can only begin playback at a keyframe, so keep them frequent
+	// while the stream comes up
+	if cfg.fastStart > 0 {
+		args = append(args, "-force_key_frames", fmt.Sprintf("expr:lt(t,%d)*gte(t,n_forced)", cfg.fastStart))
 	}
 
-	// Force consistent pixel format for compatibility
-	args = append(args, "-pix_fmt", "yuv420p")
+	// Add bitrate settings if specified
+	args = append(args, buildRateControlArgs(cfg)...)
+
+	// Force consistent pixel format for compatibility unless overridden
+	args = append(args, profile.pixelFormatArgs(cfg.pixelFormat)...)
 
-	// Only add audio encoding if the source file has audio
-	if cfg.probeInfo.hasAudio {
+	// Only add audio encoding if the source file has audio and it is wanted,
+	// or silence stands in for it
+	switch {
+	case silence:
 		args = append(args, "-c:a", "aac", "-b:a", "128k", "-ac", "2")
+	case cfg.mute:
+		args = append(args, "-an")
+	case cfg.probeInfo.hasAudio:
+		if audioFilter := buildAudioFilter(cfg); audioFilter != "" {
+			args = append(args, "-af", audioFilter)
+		}
+		args = append(args, "-c:a", "aac", "-b:a", "128k", "-ac", "2")
+		// Resample every entry to one rate so the FIFO carries uniform audio
+		// and the destination does not glitch at entry boundaries
+		if cfg.sampleRate > 0 {
+			args = append(args, "-ar", strconv.Itoa(cfg.sampleRate))
+		} else if cfg.normalizeAudio {
+			args = append(args, "-ar", strconv.Itoa(loudnormSampleRate))
+		}
+	}
+
+	// The generated video or silence never ends, so stop with the source
+	if hasStillVideo(cfg) || silence {
+		args = append(args, "-shortest")
 	}
 
+	args = append(args, metadataArgs(cfg.streamTitle, cfg.metadata)...)
 	args = append(args, "-f", "mpegts", "pipe:1")
 	return args
 }
 
+// blackVideoSource generates black frames for audio-only sources, in the
+// output format when one is set
+func blackVideoSource(cfg ffmpegArgs) string {
+	size, rate := "1280x720", 30
+	if cfg.outputSize != "" {
+		size = cfg.outputSize
+	}
+	if cfg.frameRate > 0 {
+		rate = cfg.frameRate
+	}
+	return fmt.Sprintf("color=c=black:s=%s:r=%d", size, rate)
+}
+
+// hasStillVideo reports whether the video comes from a generated input
+// rather than the source file
+func hasStillVideo(cfg ffmpegArgs) bool {
+	return cfg.imageFile != "" || cfg.probeInfo.audioOnly()
+}
+
+// needsSilentAudio reports whether silence is generated as the audio of an
+// entry that has none, or is muted, so the FIFO always carries an audio track
+func needsSilentAudio(cfg ffmpegArgs) bool {
+	return cfg.silentAudio && !hasStillVideo(cfg) && (cfg.mute || !cfg.probeInfo.hasAudio)
+}
+
+// defaultMaxMuxingQueueSize is well above ffmpeg's own default of 128
+// packets, which entries with differing parameters can overflow while the
+// streaming ffmpeg waits on one stream's first packet
+const defaultMaxMuxingQueueSize = 1024
+
+// muxingQueueOverflow is how ffmpeg reports a full muxing queue
+const muxingQueueOverflow = "Too many packets buffered for output stream"
+
+// maxMuxingQueueSize returns the -max_muxing_queue_size value with the
+// default applied
+func maxMuxingQueueSize(size int) string {
+	if size <= 0 {
+		size = defaultMaxMuxingQueueSize
+	}
+	return strconv.Itoa(size)
+}
+
 // buildStreamingArgs builds ffmpeg arguments for streaming (readFromFIFO)
 func buildStreamingArgs(cfg ffmpegArgs) []string {
-	dest := buildDestination(cfg.destination, cfg.username, cfg.password)
+	args := buildCommonArgs(cfg.logLevel)
+	args = append(args, "-progress", "pipe:1")
+	// Pace reading at realtime for live destinations, or run flat out to
+	// transcode to a file or fast sink
+	if !cfg.noRealtime {
+		args = append(args, "-re")
+	}
+	args = append(args, "-y", "-i", cfg.fifoPath)
+	args = append(args, timestampArgs(cfg.timestampMode)...)
+
+	urls := destinationURLs(cfg)
+	if len(urls) > 1 {
+		// Fan the same packets out to every destination through one tee output
+		args = append(args, "-c", "copy", "-max_muxing_queue_size", maxMuxingQueueSize(cfg.maxMuxingQueueSize))
+		args = append(args, metadataArgs(cfg.streamTitle, nil)...)
+		args = append(args, "-f", "tee", buildTeeOutput(urls))
+	} else if len(urls) == 1 {
+		// Use stream copy for both video and audio since all processing is done in writeToFIFO
+		args = append(args, "-c", "copy", "-max_muxing_queue_size", maxMuxingQueueSize(cfg.maxMuxingQueueSize))
+		args = append(args, metadataArgs(cfg.streamTitle, nil)...)
+
+		args = append(args,
+			"-f", "flv",
+			"-flvflags", "no_duration_filesize",
+			"-flush_packets", "1",
+			"-rtmp_live", "live")
+
+		// Give up on a destination that stops accepting data instead of blocking forever
+		if cfg.connectTimeout > 0 {
+			args = append(args, "-rw_timeout", strconv.Itoa(cfg.connectTimeout*1_000_000))
+		}
+
+		args = append(args, urls[0])
+	}
+
+	// Record the same packets to a local file as a second output
+	if cfg.recordPath != "" {
+		args = append(args, "-c", "copy", "-max_muxing_queue_size", maxMuxingQueueSize(cfg.maxMuxingQueueSize))
+		args = append(args, metadataArgs(cfg.streamTitle, nil)...)
+		// Fragment MP4 recordings so they stay playable if the stream is killed
+		if strings.EqualFold(filepath.Ext(cfg.recordPath), ".mp4") {
+			args = append(args, "-movflags", "+frag_keyframe+empty_moov")
+		}
+		args = append(args, cfg.recordPath)
+	}
 
+	return args
+}
+
+// buildSlateArgs builds ffmpeg arguments that loop the image with silent audio
+// into the FIFO, encoded like preprocessed entries
+func buildSlateArgs(cfg ffmpegArgs) []string {
+	profile := encoderProfileFor(cfg.encoder)
 	args := buildCommonArgs(cfg.logLevel)
-	args = append(args, "-progress", "pipe:1", "-re", "-y", "-i", cfg.fifoPath, "-fflags", "+igndts")
+	args = append(args, profile.initArgs...)
+	args = append(args,
+		"-loop", "1", "-i", cfg.imageFile,
+		"-f", "lavfi", "-i", silentAudioSource(cfg.sampleRate),
+		"-map", "0:v:0", "-map", "1:a:0")
 
-	// Use stream copy for both video and audio since all processing is done in writeToFIFO
-	args = append(args, "-c", "copy")
+	videoFilter := buildNormalizeFilter(cfg)
+	if videoFilter == "" {
+		videoFilter = evenScaleFilter
+	}
+	if profile.uploadFilter != "" {
+		videoFilter += "," + profile.uploadFilter
+	}
+	args = append(args, "-vf", videoFilter)
+	args = append(args, profile.encoderArgs(cfg.preset)...)
+
+	if cfg.keyframeInterval != "" {
+		args = append(args, "-g", cfg.keyframeInterval, "-keyint_min", cfg.keyframeInterval)
+	}
+	args = append(args, buildFrameArgs(cfg)...)
+	args = append(args, profile.pixelFormatArgs(cfg.pixelFormat)...)
 
 	args = append(args,
-		"-f", "flv",
-		"-flvflags", "no_duration_filesize",
-		"-flush_packets", "1",
-		"-rtmp_live", "live",
-		dest)
+		"-c:a", "aac", "-b:a", "128k", "-ac", "2",
+		"-f", "mpegts", "pipe:1")
 	return args
 }
 
+// buildTestPatternArgs builds ffmpeg arguments that publish a short generated
+// test pattern with a tone to destination
+func buildTestPatternArgs(destination string, seconds int) []string {
+	return []string{
+		"-hide_banner", "-loglevel", "error",
+		"-re",
+		"-f", "lavfi", "-i", "testsrc=size=320x240:rate=30",
+		"-f", "lavfi", "-i", "sine=frequency=1000:sample_rate=44100",
+		"-t", fmt.Sprintf("%d", seconds),
+		"-c:v", "libx264", "-preset", "ultrafast", "-pix_fmt", "yuv420p", "-g", "30",
+		"-c:a", "aac", "-b:a", "64k",
+		"-f", "flv",
+		destination,
+	}
+}
+
 // buildCommonArgs builds the common starting arguments for both modes
 func buildCommonArgs(logLevel string) []string {
 	if logLevel == "" {
@@ -119,15 +564,78 @@ func buildCommonArgs(logLevel string) []string {
 	return []string{"-hide_banner", "-loglevel", logLevel}
 }
 
-// getEncoderAndPreset returns the encoder and preset with defaults applied
-func getEncoderAndPreset(encoder, preset, defaultPreset string) (string, string) {
+// vaapiDevice is the render node VAAPI encoders open
+const vaapiDevice = "/dev/dri/renderD128"
+
+// encoderProfile holds what a video encoder needs beyond its name: the
+// presets it understands and how frames reach a hardware encoder's device
+type encoderProfile struct {
+	encoder       string
+	defaultPreset string   // Fastest reasonable preset, empty for encoders without presets
+	initArgs      []string // Global options placed before the inputs
+	uploadFilter  string   // Moves frames to the device after every other filter
+	pixelFormat   string   // Used when Config.PixelFormat is empty
+	qualityArgs   []string // Constant quality rate control when no bitrate is set
+}
+
+// encoderProfileFor returns the profile of the encoder. Software and unknown
+// encoders get libx264's settings.
+func encoderProfileFor(encoder string) encoderProfile {
 	if encoder == "" {
 		encoder = "libx264"
 	}
+	p := encoderProfile{
+		encoder:       encoder,
+		defaultPreset: "ultrafast",
+		pixelFormat:   defaultPixelFormat,
+		qualityArgs:   []string{"-crf", "18"},
+	}
+
+	switch {
+	case strings.HasSuffix(encoder, "_nvenc"):
+		p.defaultPreset = "p4"
+		p.qualityArgs = []string{"-rc", "vbr", "-cq", "18", "-b:v", "0"}
+	case strings.HasSuffix(encoder, "_vaapi"):
+		p.defaultPreset = ""
+		p.initArgs = []string{"-vaapi_device", vaapiDevice}
+		p.uploadFilter = "format=nv12,hwupload"
+		p.qualityArgs = []string{"-qp", "18"}
+	case strings.HasSuffix(encoder, "_qsv"):
+		p.defaultPreset = "veryfast"
+		p.pixelFormat = "nv12"
+		p.qualityArgs = []string{"-global_quality", "18"}
+	}
+	return p
+}
+
+// preset returns the preset to encode with, the profile's default unless
+// one is configured
+func (p encoderProfile) preset(preset string) string {
 	if preset == "" {
-		preset = defaultPreset
+		return p.defaultPreset
 	}
-	return encoder, preset
+	return preset
+}
+
+// encoderArgs returns the video encoder and preset arguments
+func (p encoderProfile) encoderArgs(preset string) []string {
+	args := []string{"-c:v", p.encoder}
+	if preset := p.preset(preset); preset != "" {
+		args = append(args, "-preset", preset)
+	}
+	return args
+}
+
+// pixelFormatArgs returns the output pixel format arguments. Frames uploaded
+// to a device already have the format the upload filter gave them.
+func (p encoderProfile) pixelFormatArgs(pixelFormat string) []string {
+	if p.uploadFilter != "" {
+		return nil
+	}
+	if pixelFormat == "" {
+		pixelFormat = p.pixelFormat
+	}
+	return []string{"-pix_fmt", pixelFormat}
 }
 
 // buildDestination constructs the destination URL with credentials if provided
@@ -138,13 +646,81 @@ func buildDestination(destination, username, password string) string {
 	return destination
 }
 
+// joinStreamKey appends the stream key to the destination as its final path
+// segment, with exactly one slash between them
+func joinStreamKey(destination, streamKey string) string {
+	if streamKey == "" {
+		return destination
+	}
+	return strings.TrimRight(destination, "/") + "/" + strings.TrimLeft(streamKey, "/")
+}
+
+// urlStreamKey returns the stream key in an RTMP URL, the path segment after
+// the application name such as "key" in rtmp://host/live/key. It is empty
+// when the path has no segment after the application.
+func urlStreamKey(destination string) string {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 {
+		return ""
+	}
+	return segments[len(segments)-1]
+}
+
+// redactSecrets masks every non-empty secret in s so it can be logged
+func redactSecrets(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "****")
+		}
+	}
+	return s
+}
+
 // buildVideoFilter constructs the video filter chain for preprocessing
 func buildVideoFilter(cfg ffmpegArgs) string {
+	filters := videoFilters(cfg)
+	if upload := encoderProfileFor(cfg.encoder).uploadFilter; upload != "" {
+		filters = append(filters, upload)
+	}
+	return strings.Join(filters, ",")
+}
+
+// videoFilters returns the filters applied to the video during preprocessing
+func videoFilters(cfg ffmpegArgs) []string {
 	var filters []string
 
+	// Turn the picture upright before it is scaled to the output size
+	if rotate := rotationFilter(cfg); rotate != "" {
+		filters = append(filters, rotate)
+	}
+
+	// Normalize first so overlays are laid out on the final picture. Images
+	// may have odd dimensions, which yuv420p cannot encode.
+	if normalize := buildNormalizeFilter(cfg); normalize != "" {
+		filters = append(filters, normalize)
+	} else if cfg.imageFile != "" {
+		filters = append(filters, evenScaleFilter)
+	}
+
+	// Draw text over the watermark rather than under it
+	if cfg.overlay.WatermarkFile != "" {
+		filters = append(filters, buildWatermarkOverlay(cfg.overlay.WatermarkFile, cfg.overlay.WatermarkPosition))
+	}
+
 	// Add subtitle filter if provided
 	if cfg.subtitleFile != "" {
-		filters = append(filters, fmt.Sprintf("subtitles='%s'", strings.ReplaceAll(cfg.subtitleFile, "'", "\\'")))
+		subtitles := "subtitles=" + escapeFilterArg(cfg.subtitleFile)
+		if cfg.subtitleSize != "" {
+			subtitles += ":original_size=" + cfg.subtitleSize
+		}
+		if cfg.subtitleCharenc != "" {
+			subtitles += ":charenc=" + cfg.subtitleCharenc
+		}
+		filters = append(filters, subtitles)
 	}
 
 	// Add filename overlay if enabled
@@ -152,9 +728,178 @@ func buildVideoFilter(cfg ffmpegArgs) string {
 		filters = append(filters, buildFilenameOverlay(cfg.source, cfg.overlay))
 	}
 
+	if cfg.liveTextFile != "" {
+		filters = append(filters, buildLiveTextOverlay(cfg.liveTextFile, cfg.liveTextPosition))
+	}
+
+	// Fade after overlays so they fade along with the picture
+	filters = append(filters, buildFades("fade", cfg)...)
+
+	return filters
+}
+
+// rotationFilter returns the filter that turns the source video upright when
+// AutoRotate is enabled, or "" when it needs no turning
+func rotationFilter(cfg ffmpegArgs) string {
+	if !cfg.autoRotate || hasStillVideo(cfg) {
+		return ""
+	}
+	switch cfg.probeInfo.rotation {
+	case 90:
+		return "transpose=clock"
+	case 180:
+		return "hflip,vflip"
+	case 270:
+		return "transpose=cclock"
+	}
+	return ""
+}
+
+// evenScaleFilter rounds the picture down to even dimensions
+const evenScaleFilter = "scale=trunc(iw/2)*2:trunc(ih/2)*2"
+
+// buildNormalizeFilter scales and letterboxes the picture to the output size
+// and converts it to the output frame rate. Every entry then reaches the
+// FIFO in the same format, which the streamer's stream copy relies on since
+// FLV cannot change resolution mid-stream.
+func buildNormalizeFilter(cfg ffmpegArgs) string {
+	var filters []string
+	if cfg.outputSize != "" {
+		if width, height, err := parseOutputSize(cfg.outputSize); err == nil {
+			filters = append(filters,
+				fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", width, height),
+				fmt.Sprintf("pad=%d:%d:(ow-iw)/2:(oh-ih)/2", width, height),
+				"setsar=1")
+		}
+	}
+	if cfg.frameRate > 0 {
+		filters = append(filters, "fps="+strconv.Itoa(cfg.frameRate))
+	}
 	return strings.Join(filters, ",")
 }
 
+// buildAudioFilter constructs the audio filter chain for preprocessing
+func buildAudioFilter(cfg ffmpegArgs) string {
+	return strings.Join(audioFilters(cfg), ",")
+}
+
+// loudnormFilter normalizes loudness to -16 LUFS integrated, with a -1.5 dBTP
+// true peak ceiling and an 11 LU loudness range
+const loudnormFilter = "loudnorm=I=-16:TP=-1.5:LRA=11"
+
+// loudnormSampleRate is what normalized audio is resampled to when no sample
+// rate is configured, since loudnorm outputs 192 kHz
+const loudnormSampleRate = 48000
+
+// audioFilters returns the audio filters for preprocessing in the order they
+// apply
+func audioFilters(cfg ffmpegArgs) []string {
+	var filters []string
+
+	// Normalize before fading so the fades start from the normalized level,
+	// and before the volume so it adjusts the entry relative to that level
+	if cfg.normalizeAudio {
+		filters = append(filters, loudnormFilter)
+	}
+	if cfg.volume != 0 && cfg.volume != 1 {
+		filters = append(filters, fmt.Sprintf("volume=%g", cfg.volume))
+	}
+	return append(filters, buildFades("afade", cfg)...)
+}
+
+// defaultMaxFilters bounds the filters applied to one entry. It is above the
+// most the current options can combine into.
+const defaultMaxFilters = 12
+
+// filterCount returns how many video and audio filters preprocessing applies
+func filterCount(cfg ffmpegArgs) int {
+	return len(videoFilters(cfg)) + len(audioFilters(cfg))
+}
+
+// validateFilterCount checks that preprocessing applies at most maxFilters
+// video and audio filters
+func validateFilterCount(cfg ffmpegArgs, maxFilters int) error {
+	if count := filterCount(cfg); count > maxFilters {
+		return fmt.Errorf("entry applies %d filters, more than the limit of %d", count, maxFilters)
+	}
+	return nil
+}
+
+// buildFades returns fade in/out filters using the named filter (fade or
+// afade). The fade out is timed from the end of the clip, so it is omitted
+// when the duration is unknown.
+func buildFades(filter string, cfg ffmpegArgs) []string {
+	var fades []string
+	if cfg.fadeIn > 0 {
+		fades = append(fades, fmt.Sprintf("%s=t=in:st=0:d=%g", filter, cfg.fadeIn))
+	}
+	clip := clipDuration(cfg.startTimestamp, cfg.endTimestamp, cfg.probeInfo.duration)
+	if cfg.fadeOut > 0 && clip > 0 {
+		start := max(clip-cfg.fadeOut, 0)
+		fades = append(fades, fmt.Sprintf("%s=t=out:st=%g:d=%g", filter, start, cfg.fadeOut))
+	}
+	return fades
+}
+
+// clipDuration returns how much of a file of the given duration plays
+// between startTimestamp and endTimestamp. It is 0 when unknown.
+func clipDuration(startTimestamp, endTimestamp string, duration float64) float64 {
+	if length, ok := trimLength(startTimestamp, endTimestamp); ok {
+		return length
+	}
+	if startTimestamp == "" {
+		return duration
+	}
+	start, err := parseTimestamp(startTimestamp)
+	if err != nil {
+		return duration
+	}
+	return max(duration-start, 0)
+}
+
+// trimLength returns how long an entry plays from startTimestamp until
+// endTimestamp, reporting false without a valid end after the start
+func trimLength(startTimestamp, endTimestamp string) (float64, bool) {
+	if endTimestamp == "" {
+		return 0, false
+	}
+	end, err := parseTimestamp(endTimestamp)
+	if err != nil {
+		return 0, false
+	}
+	var start float64
+	if startTimestamp != "" {
+		if start, err = parseTimestamp(startTimestamp); err != nil {
+			return 0, false
+		}
+	}
+	if end <= start {
+		return 0, false
+	}
+	return end - start, true
+}
+
+var (
+	// filterOptionEscaper escapes characters special to filter option parsing
+	filterOptionEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`)
+	// drawtextEscaper escapes characters special to drawtext text expansion
+	drawtextEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`)
+)
+
+// escapeFilterArg escapes v for use as a filter option value inside a
+// filtergraph. ffmpeg unescapes filtergraphs twice: once when splitting the
+// graph on [ ] , ; and again when splitting options on :. The value is
+// escaped for the option level and then quoted for the graph level.
+func escapeFilterArg(v string) string {
+	return "'" + strings.ReplaceAll(filterOptionEscaper.Replace(v), "'", `'\''`) + "'"
+}
+
+// escapeDrawText escapes text for drawtext's text option, first for its
+// expansion of % sequences and then as a filter option value
+func escapeDrawText(text string) string {
+	return escapeFilterArg(drawtextEscaper.Replace(text))
+}
+
 // buildFilenameOverlay constructs the drawtext filter for filename overlay
 func buildFilenameOverlay(source string, overlay OverlaySettings) string {
 	// Extract filename from path
@@ -166,8 +911,8 @@ func buildFilenameOverlay(source string, overlay OverlaySettings) string {
 	// Get position coordinates
 	x, y := getOverlayPosition(overlay.Position)
 
-	return fmt.Sprintf("drawtext=text='%s':fontsize=%d:fontcolor=white:x=%s:y=%s:box=1:boxcolor=black@0.5",
-		filename, overlay.FontSize, x, y)
+	return fmt.Sprintf("drawtext=text=%s:fontsize=%d:fontcolor=white:x=%s:y=%s:box=1:boxcolor=black@0.5",
+		escapeDrawText(filename), overlay.FontSize, x, y)
 }
 
 // getOverlayPosition returns the x,y coordinates for the overlay position
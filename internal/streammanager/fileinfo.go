@@ -1,18 +1,37 @@
 package streammanager
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+// Integrity check modes for files being enqueued
+const (
+	IntegrityCheckOff    = "off"
+	IntegrityCheckSize   = "size"
+	IntegrityCheckDecode = "decode"
+)
+
+// growthCheckDelay is how long to wait between size samples when checking
+// whether a file is still being written
+const growthCheckDelay = 500 * time.Millisecond
+
+// maxOutputDimension is the largest width or height common RTMP ingests accept
+const maxOutputDimension = 4096
+
 // ffprobeResult represents the output from ffprobe
 type ffprobeResult struct {
 	Streams []struct {
@@ -29,22 +48,124 @@ type fileProbeInfo struct {
 	needsAudioReencoding bool
 	needsExplicitMapping bool
 	hasAudio             bool
+	hasVideo             bool
+	width                int
+	height               int
+	rotation             int // Degrees clockwise the video must turn to display upright
 	duration             float64
 }
 
-// getFileDuration gets the duration of a file using ffprobe
-func getFileDuration(ctx context.Context, filePath string) (float64, error) {
-	cmd := exec.CommandContext(ctx, "ffprobe",
+// defaultProbeTimeout bounds probing one file, retries included
+const defaultProbeTimeout = 30 * time.Second
+
+// errProbeTimeout is returned once probing a file runs out of time. A file
+// that hung ffprobe is not retried.
+var errProbeTimeout = errors.New("ffprobe timed out")
+
+// defaultProbeRetries is how many times a failed ffprobe run is retried
+const defaultProbeRetries = 2
+
+var (
+	probeMu      sync.Mutex
+	probeTimeout = defaultProbeTimeout
+	probeRetries = defaultProbeRetries
+	// probeBackoff is the wait before the first retry, doubled for each one
+	probeBackoff = 250 * time.Millisecond
+	// ffprobePath is the ffprobe binary, replaced in tests
+	ffprobePath = "ffprobe"
+)
+
+// SetProbeRetries sets how many times a failed ffprobe run is retried, with
+// a doubling backoff, before the file is treated as unprobeable. Values below
+// zero are treated as zero.
+func SetProbeRetries(n int) {
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	probeRetries = max(n, 0)
+}
+
+// SetProbeTimeout bounds how long probing a file may take, retries and their
+// backoff included, before ffprobe is killed. Values of zero or below use the
+// default.
+func SetProbeTimeout(d time.Duration) {
+	if d <= 0 {
+		d = defaultProbeTimeout
+	}
+
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	probeTimeout = d
+}
+
+// runProbe runs ffprobe on filePath and returns its JSON output. A failed run
+// is retried so a momentary glitch, such as a network mount hiccup, does not
+// fail the probe; a file that fails every attempt is reported as such. All
+// attempts share one probe timeout, and a run that times out is not retried.
+func runProbe(ctx context.Context, filePath string) ([]byte, error) {
+	probeMu.Lock()
+	retries, backoff, timeout := probeRetries, probeBackoff, probeTimeout
+	probeMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output, err := runProbeOnce(ctx, filePath, timeout)
+	// A missing ffprobe will not turn up on a retry
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, err
+	}
+	for attempt := 1; err != nil && attempt <= retries; attempt++ {
+		if errors.Is(err, errProbeTimeout) {
+			return nil, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		backoff *= 2
+
+		output, err = runProbeOnce(ctx, filePath, timeout)
+	}
+	if err != nil && retries > 0 {
+		return nil, fmt.Errorf("ffprobe failed on all %d attempts: %w", retries+1, err)
+	}
+	return output, err
+}
+
+// runProbeOnce runs ffprobe on filePath a single time, killing it once ctx
+// reaches the probe timeout runProbe set
+func runProbeOnce(ctx context.Context, filePath string, timeout time.Duration) ([]byte, error) {
+	probeMu.Lock()
+	bin := ffprobePath
+	probeMu.Unlock()
+
+	cmd := exec.CommandContext(ctx, bin,
 		"-v", "quiet",
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
 		filePath)
+	// Don't wait on output pipes held open by children of a killed ffprobe
+	cmd.WaitDelay = time.Second
 
 	output, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w after %s", errProbeTimeout, timeout)
+	}
+	return output, err
+}
+
+// getFileDuration gets the duration of a file using ffprobe
+func getFileDuration(ctx context.Context, filePath string) (float64, error) {
+	output, err := runProbe(ctx, filePath)
 	if err != nil {
 		// Try to get stderr from the exit error
-		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
 			return 0, fmt.Errorf("ffprobe failed: %w\nFFprobe stderr: %s", err, string(exitErr.Stderr))
 		}
 		return 0, fmt.Errorf("ffprobe failed: %w", err)
@@ -73,34 +194,53 @@ func getFileDuration(ctx context.Context, filePath string) (float64, error) {
 	return duration, nil
 }
 
+// audioOnly reports whether the probe found audio but no video stream
+func (info fileProbeInfo) audioOnly() bool {
+	return info.hasAudio && !info.hasVideo
+}
+
+// unknownProbeInfo is assumed when a file cannot be probed: a regular file
+// with both streams that needs a full re-encode
+var unknownProbeInfo = fileProbeInfo{
+	needsVideoReencoding: true,
+	needsAudioReencoding: true,
+	needsExplicitMapping: true,
+	hasAudio:             true,
+	hasVideo:             true,
+}
+
 // probeFile runs ffprobe once and extracts all needed information
 func probeFile(ctx context.Context, logger *zap.Logger, inputPath string) fileProbeInfo {
-	cmd := exec.CommandContext(ctx, "ffprobe",
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		"-show_streams",
-		inputPath,
-	)
-
-	output, err := cmd.Output()
+	output, err := runProbe(ctx, inputPath)
 	if err != nil {
 		logger.Warn("Failed to probe file, assuming re-encoding needed", zap.Error(err))
-		return fileProbeInfo{
-			needsVideoReencoding: true,
-			needsAudioReencoding: true,
-			needsExplicitMapping: true,
-			duration:             0,
-		}
+		return unknownProbeInfo
 	}
 
+	info, err := parseProbeOutput(output)
+	if err != nil {
+		logger.Warn("Failed to parse ffprobe output", zap.Error(err))
+		return unknownProbeInfo
+	}
+	return info
+}
+
+// parseProbeOutput extracts the stream and duration information from
+// ffprobe's JSON output
+func parseProbeOutput(output []byte) (fileProbeInfo, error) {
 	var result struct {
 		Streams []struct {
 			CodecType string `json:"codec_type"`
 			CodecName string `json:"codec_name"`
 			PixFmt    string `json:"pix_fmt"`
 			Profile   string `json:"profile"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
 			Duration  string `json:"duration"`
+			Tags      struct {
+				Rotate string `json:"rotate"`
+			} `json:"tags"`
+			SideDataList []probeSideData `json:"side_data_list"`
 		} `json:"streams"`
 		Format struct {
 			Duration string `json:"duration"`
@@ -108,13 +248,7 @@ func probeFile(ctx context.Context, logger *zap.Logger, inputPath string) filePr
 	}
 
 	if err := json.Unmarshal(output, &result); err != nil {
-		logger.Warn("Failed to parse ffprobe output", zap.Error(err))
-		return fileProbeInfo{
-			needsVideoReencoding: true,
-			needsAudioReencoding: true,
-			needsExplicitMapping: true,
-			duration:             0,
-		}
+		return fileProbeInfo{}, err
 	}
 
 	info := fileProbeInfo{}
@@ -125,7 +259,13 @@ func probeFile(ctx context.Context, logger *zap.Logger, inputPath string) filePr
 		CodecName string `json:"codec_name"`
 		PixFmt    string `json:"pix_fmt"`
 		Profile   string `json:"profile"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
 		Duration  string `json:"duration"`
+		Tags      struct {
+			Rotate string `json:"rotate"`
+		} `json:"tags"`
+		SideDataList []probeSideData `json:"side_data_list"`
 	}
 
 	hasSubtitles := false
@@ -149,6 +289,9 @@ func probeFile(ctx context.Context, logger *zap.Logger, inputPath string) filePr
 
 	// Determine video re-encoding needs
 	if videoStream != nil {
+		info.hasVideo = true
+		info.width, info.height = videoStream.Width, videoStream.Height
+		info.rotation = probedRotation(videoStream.Tags.Rotate, videoStream.SideDataList)
 		switch videoStream.CodecName {
 		case "hevc", "h265":
 			info.needsVideoReencoding = true
@@ -177,9 +320,6 @@ func probeFile(ctx context.Context, logger *zap.Logger, inputPath string) filePr
 		default:
 			info.needsAudioReencoding = true
 		}
-	} else {
-		info.hasAudio = false
-		info.needsAudioReencoding = false
 	}
 
 	// Determine explicit mapping needs
@@ -201,19 +341,85 @@ func probeFile(ctx context.Context, logger *zap.Logger, inputPath string) filePr
 		}
 	}
 
-	return info
+	return info, nil
 }
 
+// validateOutputDimensions checks that the output resolution can be encoded
+// as yuv420p and is within ingest limits. Zero dimensions mean unknown.
+func validateOutputDimensions(width, height int) error {
+	if width == 0 || height == 0 {
+		return nil
+	}
+	if width%2 != 0 || height%2 != 0 {
+		return fmt.Errorf("output resolution %dx%d must have even dimensions for yuv420p", width, height)
+	}
+	if width > maxOutputDimension || height > maxOutputDimension {
+		return fmt.Errorf("output resolution %dx%d exceeds the maximum of %d pixels per side",
+			width, height, maxOutputDimension)
+	}
+	return nil
+}
+
+// readASSPlayRes returns the PlayResX and PlayResY script resolution of an
+// ASS/SSA subtitle file. Zero values mean the script does not set them.
+func readASSPlayRes(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open subtitle file: %w", err)
+	}
+	defer f.Close()
+
+	var x, y int
+	inScriptInfo := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if strings.HasPrefix(line, "[") {
+			if inScriptInfo {
+				break
+			}
+			inScriptInfo = strings.EqualFold(line, "[Script Info]")
+			continue
+		}
+		if !inScriptInfo {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "PlayResX":
+			x, _ = strconv.Atoi(strings.TrimSpace(value))
+		case "PlayResY":
+			y, _ = strconv.Atoi(strings.TrimSpace(value))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to read subtitle file: %w", err)
+	}
+	return x, y, nil
+}
+
+// timestampRegex matches HH:MM:SS timestamps with optional fractional seconds
+var timestampRegex = regexp.MustCompile(`^(\d+):(\d{2}):(\d{2})(?:\.(\d+))?$`)
+
 // parseTimestamp converts timestamp string to seconds
 func parseTimestamp(timestamp string) (float64, error) {
 	// Try parsing as seconds first
 	if seconds, err := strconv.ParseFloat(timestamp, 64); err == nil {
+		if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return 0, errors.New("timestamp must be a finite number of seconds")
+		}
+		if seconds < 0 {
+			return 0, errors.New("timestamp must not be negative")
+		}
 		return seconds, nil
 	}
 
 	// Try parsing as HH:MM:SS format
-	timeRegex := regexp.MustCompile(`^(\d{1,2}):(\d{2}):(\d{2})(?:\.(\d+))?$`)
-	matches := timeRegex.FindStringSubmatch(timestamp)
+	matches := timestampRegex.FindStringSubmatch(timestamp)
 	if len(matches) < 4 {
 		return 0, errors.New("timestamp must be in HH:MM:SS format or numeric seconds")
 	}
@@ -231,3 +437,97 @@ func parseTimestamp(timestamp string) (float64, error) {
 	totalSeconds := float64(hours*3600+minutes*60+seconds) + milliseconds
 	return totalSeconds, nil
 }
+
+// checkFileGrowing returns an error if the file size changes over delay,
+// which indicates the file is still being copied or downloaded
+func checkFileGrowing(ctx context.Context, filePath string, delay time.Duration) error {
+	before, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+	}
+
+	after, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if before.Size() != after.Size() || !before.ModTime().Equal(after.ModTime()) {
+		return fmt.Errorf("file is still being written (size changed from %d to %d bytes)", before.Size(), after.Size())
+	}
+	return nil
+}
+
+// checkTailDecodes decodes the last few seconds of the file to catch
+// truncated downloads that would otherwise fail partway through streaming
+func checkTailDecodes(ctx context.Context, filePath string) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-v", "error",
+		"-xerror",
+		"-sseof", "-3",
+		"-i", filePath,
+		"-f", "null", "-")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("file appears incomplete or corrupt: %w\nFFmpeg stderr: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// verifyFileIntegrity runs the checks enabled by mode against filePath
+func verifyFileIntegrity(ctx context.Context, filePath, mode string) error {
+	switch mode {
+	case "", IntegrityCheckOff:
+		return nil
+	case IntegrityCheckSize, IntegrityCheckDecode:
+	default:
+		return fmt.Errorf("unknown integrity check mode: %s", mode)
+	}
+
+	if err := checkFileGrowing(ctx, filePath, growthCheckDelay); err != nil {
+		return err
+	}
+
+	if mode == IntegrityCheckDecode {
+		return checkTailDecodes(ctx, filePath)
+	}
+	return nil
+}
+
+// probeSideData is side data ffprobe reports on a stream, such as the
+// display matrix
+type probeSideData struct {
+	Rotation float64 `json:"rotation"`
+}
+
+// probedRotation returns the degrees clockwise a video stream must turn to
+// display upright, from its display matrix or, in older files, its rotate
+// tag. The display matrix gives the rotation counterclockwise. Anything other
+// than a quarter turn is ignored.
+func probedRotation(rotateTag string, sideData []probeSideData) int {
+	degrees := 0
+	for _, sd := range sideData {
+		if sd.Rotation != 0 {
+			degrees = -int(math.Round(sd.Rotation))
+			break
+		}
+	}
+	if degrees == 0 && rotateTag != "" {
+		if tag, err := strconv.Atoi(rotateTag); err == nil {
+			degrees = tag
+		}
+	}
+
+	degrees = ((degrees % 360) + 360) % 360
+	if degrees%90 != 0 {
+		return 0
+	}
+	return degrees
+}
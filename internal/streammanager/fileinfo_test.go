@@ -1,8 +1,18 @@
 package streammanager
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 func TestParseTimestamp(t *testing.T) {
@@ -144,9 +154,29 @@ func TestParseTimestamp(t *testing.T) {
 			expectErr: true,
 		},
 		{
-			name:     "invalid format - negative number",
-			input:    "-123",
-			expected: -123, // Actually valid - negative seconds are allowed
+			name:      "invalid format - negative number",
+			input:     "-123",
+			expectErr: true,
+		},
+		{
+			name:      "invalid format - negative decimal",
+			input:     "-0.5",
+			expectErr: true,
+		},
+		{
+			name:      "invalid format - NaN",
+			input:     "NaN",
+			expectErr: true,
+		},
+		{
+			name:      "invalid format - infinity",
+			input:     "Inf",
+			expectErr: true,
+		},
+		{
+			name:      "invalid format - negative hours",
+			input:     "-01:00:00",
+			expectErr: true,
 		},
 		{
 			name:      "invalid format - non-numeric hour",
@@ -233,9 +263,14 @@ func TestParseTimestampBoundaryConditions(t *testing.T) {
 		expectErr bool
 	}{
 		{
-			name:      "very large hour value",
-			input:     "999:59:59",
-			expectErr: true, // Regex only allows 1-2 digits for hours
+			name:     "very large hour value",
+			input:    "999:59:59",
+			expected: 999*3600 + 59*60 + 59,
+		},
+		{
+			name:     "hundred hours",
+			input:    "100:00:00",
+			expected: 100 * 3600,
 		},
 		{
 			name:     "very small decimal",
@@ -277,3 +312,349 @@ func TestParseTimestampBoundaryConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckFileGrowing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, []byte("complete"), 0o644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	if err := checkFileGrowing(context.Background(), path, 10*time.Millisecond); err != nil {
+		t.Errorf("checkFileGrowing() on a stable file returned error: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("failed to open test file: %v", err)
+	}
+	defer f.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		time.Sleep(20 * time.Millisecond)
+		_, _ = f.Write([]byte("more data"))
+	}()
+
+	if err := checkFileGrowing(context.Background(), path, 100*time.Millisecond); err == nil {
+		t.Error("checkFileGrowing() on a growing file returned nil, want error")
+	}
+	<-done
+}
+
+func TestVerifyFileIntegrityUnknownMode(t *testing.T) {
+	if err := verifyFileIntegrity(context.Background(), "/nonexistent", "bogus"); err == nil {
+		t.Error("verifyFileIntegrity() with unknown mode returned nil, want error")
+	}
+	if err := verifyFileIntegrity(context.Background(), "/nonexistent", IntegrityCheckOff); err != nil {
+		t.Errorf("verifyFileIntegrity() with mode off returned error: %v", err)
+	}
+}
+
+func TestValidateOutputDimensions(t *testing.T) {
+	tests := []struct {
+		name      string
+		width     int
+		height    int
+		expectErr bool
+	}{
+		{name: "unknown", width: 0, height: 0},
+		{name: "1080p", width: 1920, height: 1080},
+		{name: "odd width", width: 1279, height: 720, expectErr: true},
+		{name: "odd height", width: 1280, height: 721, expectErr: true},
+		{name: "too wide", width: 7680, height: 4320, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOutputDimensions(tt.width, tt.height)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("validateOutputDimensions(%d, %d) error = %v, expectErr %v",
+					tt.width, tt.height, err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestReadASSPlayRes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		x, y    int
+	}{
+		{
+			name:    "script resolution set",
+			content: "\ufeff[Script Info]\nTitle: test\nPlayResX: 1280\nPlayResY: 720\n\n[V4+ Styles]\nPlayResX: 1\n",
+			x:       1280,
+			y:       720,
+		},
+		{
+			name:    "script resolution unset",
+			content: "[Script Info]\nTitle: test\n\n[Events]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "subs.ass")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatalf("failed to write subtitle file: %v", err)
+			}
+
+			x, y, err := readASSPlayRes(path)
+			if err != nil {
+				t.Fatalf("readASSPlayRes() error = %v", err)
+			}
+			if x != tt.x || y != tt.y {
+				t.Errorf("readASSPlayRes() = %dx%d, want %dx%d", x, y, tt.x, tt.y)
+			}
+		})
+	}
+}
+
+func TestParseProbeOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected fileProbeInfo
+	}{
+		{
+			name: "audio and video",
+			output: `{"streams":[{"codec_type":"video","codec_name":"h264","pix_fmt":"yuv420p","width":1920,"height":1080},` +
+				`{"codec_type":"audio","codec_name":"aac"}],"format":{"duration":"60.5"}}`,
+			expected: fileProbeInfo{hasVideo: true, hasAudio: true, width: 1920, height: 1080, duration: 60.5},
+		},
+		{
+			name:     "audio only",
+			output:   `{"streams":[{"codec_type":"audio","codec_name":"flac","duration":"180"}],"format":{}}`,
+			expected: fileProbeInfo{needsVideoReencoding: true, needsAudioReencoding: true, hasAudio: true, duration: 180},
+		},
+		{
+			name:     "video only",
+			output:   `{"streams":[{"codec_type":"video","codec_name":"hevc","width":1280,"height":720}],"format":{"duration":"30"}}`,
+			expected: fileProbeInfo{needsVideoReencoding: true, hasVideo: true, width: 1280, height: 720, duration: 30},
+		},
+		{
+			name: "portrait phone video with display matrix",
+			output: `{"streams":[{"codec_type":"video","codec_name":"h264","pix_fmt":"yuv420p","width":1920,"height":1080,` +
+				`"side_data_list":[{"side_data_type":"Display Matrix","rotation":-90}]}],"format":{"duration":"10"}}`,
+			expected: fileProbeInfo{hasVideo: true, width: 1920, height: 1080, rotation: 90, duration: 10},
+		},
+		{
+			name: "rotate tag",
+			output: `{"streams":[{"codec_type":"video","codec_name":"h264","pix_fmt":"yuv420p","width":1920,"height":1080,` +
+				`"tags":{"rotate":"270"}}],"format":{"duration":"10"}}`,
+			expected: fileProbeInfo{hasVideo: true, width: 1920, height: 1080, rotation: 270, duration: 10},
+		},
+		{
+			name: "upside down",
+			output: `{"streams":[{"codec_type":"video","codec_name":"h264","pix_fmt":"yuv420p","width":1920,"height":1080,` +
+				`"side_data_list":[{"side_data_type":"Display Matrix","rotation":180}]}],"format":{"duration":"10"}}`,
+			expected: fileProbeInfo{hasVideo: true, width: 1920, height: 1080, rotation: 180, duration: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseProbeOutput([]byte(tt.output))
+			if err != nil {
+				t.Fatalf("parseProbeOutput() error = %v", err)
+			}
+			if info != tt.expected {
+				t.Errorf("parseProbeOutput() = %+v, want %+v", info, tt.expected)
+			}
+		})
+	}
+
+	if _, err := parseProbeOutput([]byte("not json")); err == nil {
+		t.Error("parseProbeOutput() with invalid JSON returned nil, want error")
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	// A stand-in ffprobe that hangs like a probe of a stalled network source
+	script := filepath.Join(t.TempDir(), "ffprobe")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexec sleep 10\n"), 0o755); err != nil {
+		t.Fatalf("failed to write ffprobe script: %v", err)
+	}
+
+	probeMu.Lock()
+	ffprobePath = script
+	probeMu.Unlock()
+	SetProbeTimeout(50 * time.Millisecond)
+	SetProbeRetries(0)
+	t.Cleanup(func() {
+		probeMu.Lock()
+		ffprobePath = "ffprobe"
+		probeMu.Unlock()
+		SetProbeTimeout(0)
+		SetProbeRetries(defaultProbeRetries)
+	})
+
+	start := time.Now()
+	_, err := getFileDuration(context.Background(), "/path/to/video.mp4")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("getFileDuration() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("getFileDuration() took %v, want it bounded by the probe timeout", elapsed)
+	}
+}
+
+func TestProbeTimeoutNotRetried(t *testing.T) {
+	attempts := filepath.Join(t.TempDir(), "attempts")
+	withFakeProbe(t, fmt.Sprintf("echo x >> %s\nexec sleep 10\n", attempts))
+	SetProbeRetries(2)
+	SetProbeTimeout(100 * time.Millisecond)
+	t.Cleanup(func() { SetProbeTimeout(0) })
+
+	start := time.Now()
+	_, err := runProbe(context.Background(), "/path/to/video.mp4")
+	if !errors.Is(err, errProbeTimeout) {
+		t.Errorf("runProbe() error = %v, want %v", err, errProbeTimeout)
+	}
+	// The timeout covers the whole probe, not each attempt
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("runProbe() took %v, want it bounded by one probe timeout", elapsed)
+	}
+
+	data, err := os.ReadFile(attempts)
+	if err != nil {
+		t.Fatalf("failed to read attempts: %v", err)
+	}
+	if got := strings.Count(string(data), "\n"); got != 1 {
+		t.Errorf("ffprobe ran %d times after timing out, want 1", got)
+	}
+}
+
+// withFakeProbe replaces ffprobe with a shell script for the test and makes
+// retries immediate
+func withFakeProbe(t *testing.T, script string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ffprobe")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatalf("failed to write ffprobe script: %v", err)
+	}
+
+	probeMu.Lock()
+	ffprobePath, probeBackoff = path, time.Millisecond
+	probeMu.Unlock()
+	t.Cleanup(func() {
+		probeMu.Lock()
+		ffprobePath, probeBackoff = "ffprobe", 250*time.Millisecond
+		probeMu.Unlock()
+		SetProbeRetries(defaultProbeRetries)
+	})
+}
+
+func TestProbeRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		retries      int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "transient failure", failures: 1, retries: 2, wantAttempts: 2},
+		{name: "consistent failure", failures: 10, retries: 2, wantErr: true, wantAttempts: 3},
+		{name: "no retries", failures: 1, retries: 0, wantErr: true, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each run appends to the attempts file and fails until enough
+			// attempts have been made
+			attempts := filepath.Join(t.TempDir(), "attempts")
+			withFakeProbe(t, fmt.Sprintf(`echo x >> %s
+if [ "$(wc -l < %s)" -le %d ]; then exit 1; fi
+echo '{"format":{"duration":"12.5"}}'
+`, attempts, attempts, tt.failures))
+			SetProbeRetries(tt.retries)
+
+			duration, err := getFileDuration(context.Background(), "/path/to/video.mp4")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getFileDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && duration != 12.5 {
+				t.Errorf("getFileDuration() = %v, want 12.5", duration)
+			}
+
+			data, err := os.ReadFile(attempts)
+			if err != nil {
+				t.Fatalf("failed to read attempts: %v", err)
+			}
+			if got := strings.Count(string(data), "\n"); got != tt.wantAttempts {
+				t.Errorf("ffprobe ran %d times, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestAutoRotate(t *testing.T) {
+	// A phone video recorded in portrait, stored landscape with a display matrix
+	withFakeProbe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"h264","pix_fmt":"yuv420p","width":1920,"height":1080,`+
+		`"side_data_list":[{"side_data_type":"Display Matrix","rotation":-90}]},{"codec_type":"audio","codec_name":"aac"}],"format":{"duration":"10"}}'
+`)
+
+	info := probeFile(context.Background(), zap.NewNop(), "/path/to/phone.mp4")
+	if info.rotation != 90 {
+		t.Fatalf("probeFile() rotation = %d, want 90", info.rotation)
+	}
+
+	tests := []struct {
+		name       string
+		autoRotate bool
+		wantFilter string
+	}{
+		{name: "enabled", autoRotate: true, wantFilter: "transpose=clock"},
+		{name: "disabled", autoRotate: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := buildPreprocessingArgs(ffmpegArgs{
+				source:     "/path/to/phone.mp4",
+				autoRotate: tt.autoRotate,
+				probeInfo:  info,
+			})
+
+			if got := argValue(args, "-vf"); got != tt.wantFilter {
+				t.Errorf("-vf = %q, want %q", got, tt.wantFilter)
+			}
+			if got := slices.Contains(args, "-noautorotate"); got != tt.autoRotate {
+				t.Errorf("-noautorotate present = %v, want %v", got, tt.autoRotate)
+			}
+		})
+	}
+}
+
+func TestValidateEndTimestamp(t *testing.T) {
+	withFakeProbe(t, `echo '{"format":{"duration":"10"}}'
+`)
+	sm := newTestStreamManager(t)
+
+	tests := []struct {
+		name    string
+		start   string
+		end     string
+		wantErr bool
+	}{
+		{name: "no end"},
+		{name: "end after start", start: "0:00:02", end: "0:00:05"},
+		{name: "end without start", end: "5"},
+		{name: "end at duration", start: "2", end: "10"},
+		{name: "end before start", start: "0:00:05", end: "0:00:02", wantErr: true},
+		{name: "end at start", start: "5", end: "5", wantErr: true},
+		{name: "end after duration", end: "0:00:12", wantErr: true},
+		{name: "invalid end", end: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sm.validateEndTimestamp(context.Background(), "/path/to/video.mp4", tt.start, tt.end)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateEndTimestamp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
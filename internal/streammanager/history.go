@@ -0,0 +1,53 @@
+package streammanager
+
+import "time"
+
+// maxHistory bounds the number of finished entries kept in the play history
+const maxHistory = 50
+
+// Outcomes recorded for finished entries
+const (
+	outcomeCompleted = "completed"
+	outcomeSkipped   = "skipped"
+	outcomeStopped   = "stopped"
+	outcomeFailed    = "failed"
+)
+
+// historyEntry records an entry that finished playing and how it ended
+type historyEntry struct {
+	ID        string    `json:"id"`
+	File      string    `json:"file"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// recordHistory appends to the play history, dropping the oldest entries
+// beyond maxHistory. The caller must hold s.mu.
+func (s *StreamManager) recordHistory(h historyEntry) {
+	s.history = append(s.history, h)
+	if len(s.history) > maxHistory {
+		s.history = s.history[len(s.history)-maxHistory:]
+	}
+}
+
+// History returns the play history, oldest first
+func (s *StreamManager) History() []historyEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]historyEntry, len(s.history))
+	copy(result, s.history)
+	return result
+}
+
+// ClearHistory empties the play history and returns the number of entries removed
+func (s *StreamManager) ClearHistory() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.history)
+	s.history = nil
+	return n
+}
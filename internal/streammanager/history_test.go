@@ -0,0 +1,57 @@
+package streammanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestFinishEntryOutcomes(t *testing.T) {
+	sm := newTestStreamManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	sm.ctx = ctx
+
+	sm.finishEntry(entry{ID: "1", File: "a.mp4"}, nil)
+	sm.finishEntry(entry{ID: "2", File: "b.mp4"}, context.Canceled)
+	sm.finishEntry(entry{ID: "3", File: "c.mp4"}, errors.New("boom"))
+	cancel()
+	sm.finishEntry(entry{ID: "4", File: "d.mp4"}, context.Canceled)
+
+	expected := []string{outcomeCompleted, outcomeSkipped, outcomeFailed, outcomeStopped}
+	history := sm.History()
+	if len(history) != len(expected) {
+		t.Fatalf("len(History()) = %d, want %d", len(history), len(expected))
+	}
+	for i, outcome := range expected {
+		if history[i].Outcome != outcome {
+			t.Errorf("History()[%d].Outcome = %q, want %q", i, history[i].Outcome, outcome)
+		}
+	}
+	if history[2].Error != "boom" {
+		t.Errorf("History()[2].Error = %q, want %q", history[2].Error, "boom")
+	}
+}
+
+func TestHistoryBounded(t *testing.T) {
+	sm := newTestStreamManager(t)
+
+	for i := range maxHistory + 10 {
+		sm.recordHistory(historyEntry{ID: fmt.Sprintf("%d", i)})
+	}
+
+	history := sm.History()
+	if len(history) != maxHistory {
+		t.Fatalf("len(History()) = %d, want %d", len(history), maxHistory)
+	}
+	if history[0].ID != "10" {
+		t.Errorf("oldest history ID = %q, want %q", history[0].ID, "10")
+	}
+
+	if removed := sm.ClearHistory(); removed != maxHistory {
+		t.Errorf("ClearHistory() = %d, want %d", removed, maxHistory)
+	}
+	if len(sm.History()) != 0 {
+		t.Error("History() not empty after ClearHistory")
+	}
+}
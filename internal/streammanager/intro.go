@@ -0,0 +1,63 @@
+package streammanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// introEntryID identifies the intro while it is the current entry
+const introEntryID = "intro"
+
+// validateIntroFile checks that the intro file exists. An empty value plays
+// no intro.
+func validateIntroFile(introFile string) error {
+	if introFile == "" {
+		return nil
+	}
+	if _, err := os.Stat(introFile); err != nil {
+		return fmt.Errorf("intro file is not readable: %w", err)
+	}
+	return nil
+}
+
+// playIntro writes Config.IntroFile to the FIFO once, before any queued
+// entry. Unlike queued entries it is not recorded in the history or replayed
+// by the loop end of queue action. A failed intro is logged and the queue
+// plays without it.
+func (s *StreamManager) playIntro(ctx context.Context) {
+	s.mu.RLock()
+	introFile := s.config.IntroFile
+	s.mu.RUnlock()
+
+	if introFile == "" {
+		return
+	}
+
+	intro := entry{ID: introEntryID, File: introFile}
+	entryCtx := s.beginEntry(intro, 1)
+
+	s.logger.Info("Playing intro", zap.String("file", introFile))
+	err := s.writeToFIFO(entryCtx, intro)
+
+	s.mu.Lock()
+	s.currentEntry = nil
+	if s.currentCancel != nil {
+		s.currentCancel()
+		s.currentCancel = nil
+	}
+	s.introPlayed = true
+	s.mu.Unlock()
+
+	switch {
+	case err == nil:
+		s.logger.Info("Intro finished", zap.String("file", introFile))
+	case errors.Is(err, context.Canceled):
+		s.logger.Info("Intro was skipped", zap.String("file", introFile))
+	default:
+		s.logger.Warn("Intro failed, continuing with the queue", zap.String("file", introFile), zap.Error(err))
+	}
+}
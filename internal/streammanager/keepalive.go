@@ -0,0 +1,207 @@
+package streammanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// tsPacketSize is the size of an MPEG-TS packet. The FIFO is only switched
+// between sources on packet boundaries so the reader never sees a torn packet.
+const tsPacketSize = 188
+
+// validateKeepAlive checks the keep-alive delay and that there is a slate to
+// fill stalls with
+func validateKeepAlive(keepAlive int, slateImage string) error {
+	if keepAlive < 0 {
+		return errors.New("keep-alive must not be negative")
+	}
+	if keepAlive > 0 {
+		if slateImage == "" {
+			return errors.New("keep-alive requires a slate image")
+		}
+		if err := validateImageFile(slateImage); err != nil {
+			return fmt.Errorf("invalid slate image: %w", err)
+		}
+	}
+	return nil
+}
+
+// fillerFunc starts a source of TS packets to fill a preprocessing stall. It
+// returns the packets and a function that stops the source and waits for it.
+type fillerFunc func(ctx context.Context) (<-chan []byte, func())
+
+// keepAliveCopy copies the preprocessed entry from src to dst. When src
+// produces nothing for keepAlive, packets from fill are written instead
+// until the entry catches up, so the destination never sees the output stop.
+func (s *StreamManager) keepAliveCopy(ctx context.Context, dst io.Writer, src io.Reader, keepAlive time.Duration, fill fillerFunc) error {
+	readCtx, cancelRead := context.WithCancel(ctx)
+	defer cancelRead()
+
+	entry := make(chan []byte)
+	entryErr := make(chan error, 1)
+	go func() {
+		entryErr <- readPackets(readCtx, src, entry)
+	}()
+
+	var (
+		filler     <-chan []byte
+		stopFiller func()
+	)
+	stopFilling := func() {
+		if stopFiller == nil {
+			return
+		}
+		stopFiller()
+		filler, stopFiller = nil, nil
+		s.setBuffering(false)
+	}
+	defer stopFilling()
+
+	stall := s.clock.After(keepAlive)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case pkt, ok := <-entry:
+			if !ok {
+				return <-entryErr
+			}
+			if stopFiller != nil {
+				stopFilling()
+				s.logger.Info("Preprocessing caught up, resuming entry")
+			}
+			if _, err := dst.Write(pkt); err != nil {
+				return fmt.Errorf("failed to write to fifo: %w", err)
+			}
+			stall = s.clock.After(keepAlive)
+
+		case <-stall:
+			stall = nil
+			s.logger.Warn("Preprocessing stalled, filling with slate", zap.Duration("after", keepAlive))
+			s.setBuffering(true)
+			filler, stopFiller = fill(ctx)
+
+		case pkt, ok := <-filler:
+			if !ok {
+				// The filler ended on its own; try again after another stall
+				stopFilling()
+				stall = s.clock.After(keepAlive)
+				continue
+			}
+			if _, err := dst.Write(pkt); err != nil {
+				return fmt.Errorf("failed to write to fifo: %w", err)
+			}
+		}
+	}
+}
+
+// runWithKeepAlive runs cmd, whose stdout is pw, copying its output from pr
+// to fifo and filling any stall longer than keepAlive with the slate
+func (s *StreamManager) runWithKeepAlive(ctx context.Context, cmd *exec.Cmd, fifo io.Writer, pr *io.PipeReader, pw *io.PipeWriter, keepAlive time.Duration) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	copied := make(chan error, 1)
+	go func() {
+		err := s.keepAliveCopy(ctx, fifo, pr, keepAlive, s.startSlateFiller)
+		// Unblock ffmpeg's writes if the copy gave up early
+		_ = pr.CloseWithError(io.ErrClosedPipe)
+		copied <- err
+	}()
+
+	err := cmd.Wait()
+	_ = pw.Close()
+	copyErr := <-copied
+
+	if err != nil {
+		return err
+	}
+	return copyErr
+}
+
+// startSlateFiller is the fillerFunc that encodes the slate image for as long
+// as the stall lasts
+func (s *StreamManager) startSlateFiller(ctx context.Context) (<-chan []byte, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	packets := make(chan []byte)
+	done := make(chan struct{})
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", s.slateArgs()...)
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		s.logger.Error("Failed to start keep-alive slate", zap.Error(err))
+		close(packets)
+		close(done)
+		return packets, cancel
+	}
+
+	go func() {
+		defer close(done)
+		_ = readPackets(ctx, stdout, packets)
+		_ = cmd.Wait()
+	}()
+
+	return packets, func() {
+		cancel()
+		<-done
+	}
+}
+
+// readPackets reads r and sends it to out in chunks of whole TS packets until
+// r ends, then closes out. A trailing partial packet is sent as is.
+func readPackets(ctx context.Context, r io.Reader, out chan<- []byte) error {
+	defer close(out)
+
+	send := func(b []byte) error {
+		pkt := make([]byte, len(b))
+		copy(pkt, b)
+		select {
+		case out <- pkt:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	buf := make([]byte, 32*1024)
+	var pending []byte
+	for {
+		n, err := r.Read(buf)
+		pending = append(pending, buf[:n]...)
+
+		if whole := len(pending) / tsPacketSize * tsPacketSize; whole > 0 {
+			if sendErr := send(pending[:whole]); sendErr != nil {
+				return sendErr
+			}
+			pending = append(pending[:0], pending[whole:]...)
+		}
+
+		if errors.Is(err, io.EOF) {
+			if len(pending) > 0 {
+				return send(pending)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// setBuffering records whether the slate is covering a preprocessing stall
+func (s *StreamManager) setBuffering(buffering bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffering = buffering
+}
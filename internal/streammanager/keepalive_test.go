@@ -0,0 +1,185 @@
+package streammanager
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe to read while the copier writes to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestValidateKeepAlive(t *testing.T) {
+	tests := []struct {
+		name       string
+		keepAlive  int
+		slateImage string
+		wantErr    string
+	}{
+		{name: "disabled", keepAlive: 0},
+		{name: "negative", keepAlive: -1, wantErr: "must not be negative"},
+		{name: "no slate image", keepAlive: 5, wantErr: "requires a slate image"},
+		{name: "missing slate image", keepAlive: 5, slateImage: "/nonexistent/slate.png", wantErr: "invalid slate image"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateKeepAlive(tt.keepAlive, tt.slateImage)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateKeepAlive() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateKeepAlive() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadPackets(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		expected []int
+	}{
+		{name: "empty", size: 0, expected: nil},
+		{name: "whole packets", size: 2 * tsPacketSize, expected: []int{tsPacketSize, tsPacketSize}},
+		{name: "trailing partial packet", size: tsPacketSize + 12, expected: []int{tsPacketSize, 12}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Reading a byte at a time makes every packet straddle reads
+			r := iotest.OneByteReader(bytes.NewReader(make([]byte, tt.size)))
+			out := make(chan []byte)
+
+			errc := make(chan error, 1)
+			go func() {
+				errc <- readPackets(context.Background(), r, out)
+			}()
+
+			var sizes []int
+			for pkt := range out {
+				sizes = append(sizes, len(pkt))
+			}
+			if err := <-errc; err != nil {
+				t.Fatalf("readPackets() error = %v", err)
+			}
+			if len(sizes) != len(tt.expected) {
+				t.Fatalf("readPackets() sent %v, want %v", sizes, tt.expected)
+			}
+			for i := range sizes {
+				if sizes[i] != tt.expected[i] {
+					t.Fatalf("readPackets() sent %v, want %v", sizes, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestKeepAliveCopyFillsStall(t *testing.T) {
+	sm := newTestStreamManager(t)
+	clock := newFakeClock()
+	sm.clock = clock
+
+	const keepAlive = 5 * time.Second
+	slatePacket := bytes.Repeat([]byte{'S'}, tsPacketSize)
+	entryPacket := bytes.Repeat([]byte{'E'}, tsPacketSize)
+
+	var fillerStopped sync.WaitGroup
+	fill := func(ctx context.Context) (<-chan []byte, func()) {
+		ctx, cancel := context.WithCancel(ctx)
+		packets := make(chan []byte)
+		fillerStopped.Add(1)
+		go func() {
+			defer fillerStopped.Done()
+			defer close(packets)
+			for {
+				select {
+				case packets <- slatePacket:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return packets, func() {
+			cancel()
+			fillerStopped.Wait()
+		}
+	}
+
+	var dst syncBuffer
+	src, srcWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- sm.keepAliveCopy(context.Background(), &dst, src, keepAlive, fill)
+	}()
+
+	waitUntil(t, "the stall timer", func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return len(clock.waiters) == 1
+	})
+	clock.Advance(keepAlive)
+
+	waitUntil(t, "slate packets", func() bool { return len(dst.Bytes()) >= 2*tsPacketSize })
+	if buffering, _ := sm.Status()["buffering"].(bool); !buffering {
+		t.Error("Status() buffering = false while the slate fills a stall")
+	}
+
+	if _, err := srcWriter.Write(entryPacket); err != nil {
+		t.Fatalf("writing entry packet: %v", err)
+	}
+	waitUntil(t, "the entry packet", func() bool { return bytes.HasSuffix(dst.Bytes(), entryPacket) })
+	if buffering, _ := sm.Status()["buffering"].(bool); buffering {
+		t.Error("Status() buffering = true after the entry resumed")
+	}
+
+	_ = srcWriter.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("keepAliveCopy() error = %v", err)
+	}
+
+	// The slate must stop before the entry resumes, on a packet boundary
+	out := dst.Bytes()
+	if len(out)%tsPacketSize != 0 {
+		t.Fatalf("output length %d is not a whole number of packets", len(out))
+	}
+	slate := out[:len(out)-tsPacketSize]
+	if len(bytes.Trim(slate, "S")) != 0 {
+		t.Error("output has entry data interleaved with the slate")
+	}
+}
@@ -0,0 +1,70 @@
+package streammanager
+
+import (
+	"fmt"
+	"os"
+)
+
+// defaultLiveTextPosition keeps the live text clear of the filename overlay,
+// which defaults to bottom-right
+const defaultLiveTextPosition = "bottom-left"
+
+// createLiveTextFile creates the file the live text overlay reads from when
+// Config.LiveText is set. It lives for the whole session.
+func (s *StreamManager) createLiveTextFile() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.config.LiveText {
+		return nil
+	}
+
+	f, err := os.CreateTemp("", "streammanager-text-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create live text file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("failed to create live text file: %w", err)
+	}
+
+	s.liveTextPath = f.Name()
+	return nil
+}
+
+// SetOverlayText replaces the text burned in by the live text overlay. The
+// change shows up within a frame or two without restarting the entry. It
+// reports false when the current session has no live text overlay.
+func (s *StreamManager) SetOverlayText(text string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.liveTextPath == "" {
+		return false, nil
+	}
+
+	// drawtext rereads the file every frame, so replace it atomically rather
+	// than letting a frame see it half written
+	tmp := s.liveTextPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(text), 0o644); err != nil {
+		return true, fmt.Errorf("failed to write live text: %w", err)
+	}
+	if err := os.Rename(tmp, s.liveTextPath); err != nil {
+		_ = os.Remove(tmp)
+		return true, fmt.Errorf("failed to replace live text: %w", err)
+	}
+	return true, nil
+}
+
+// buildLiveTextOverlay constructs the drawtext filter that reloads its text
+// from textFile on every frame. Expansion is disabled so the text is shown
+// exactly as written.
+func buildLiveTextOverlay(textFile, position string) string {
+	if position == "" {
+		position = defaultLiveTextPosition
+	}
+	x, y := getOverlayPosition(position)
+
+	return fmt.Sprintf("drawtext=textfile=%s:reload=1:expansion=none:fontsize=24:fontcolor=white:x=%s:y=%s:box=1:boxcolor=black@0.5",
+		escapeFilterArg(textFile), x, y)
+}
@@ -0,0 +1,31 @@
+package streammanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetOverlayText(t *testing.T) {
+	sm := newTestStreamManager(t)
+
+	if found, err := sm.SetOverlayText("Now Playing: X"); found || err != nil {
+		t.Fatalf("SetOverlayText() without live text = %v, %v, want false, nil", found, err)
+	}
+
+	sm.liveTextPath = filepath.Join(t.TempDir(), "text.txt")
+	for _, text := range []string{"Now Playing: X", "Now Playing: 100% Y"} {
+		found, err := sm.SetOverlayText(text)
+		if !found || err != nil {
+			t.Fatalf("SetOverlayText(%q) = %v, %v, want true, nil", text, found, err)
+		}
+
+		got, err := os.ReadFile(sm.liveTextPath)
+		if err != nil {
+			t.Fatalf("failed to read live text file: %v", err)
+		}
+		if string(got) != text {
+			t.Errorf("live text file = %q, want %q", got, text)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package streammanager
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Where metadata ends up:
+//
+//   - Config.StreamTitle is set as the title of the streaming output. FLV
+//     writes it into onMetaData once when the destination connects, so RTMP
+//     destinations (Twitch, YouTube, Owncast) and recordings all show it.
+//   - EntryOptions.Metadata is set on the entry's own MPEG-TS segment, where
+//     "title" becomes the service name and a new SDT is sent at the start of
+//     every entry. Readers of the FIFO and .ts recordings of the preprocessed
+//     output see the changes mid-stream; the FLV muxer never rewrites
+//     onMetaData, so RTMP destinations do not.
+
+// validateMetadata checks that every tag has a usable key
+func validateMetadata(metadata map[string]string) error {
+	for key := range metadata {
+		if strings.TrimSpace(key) == "" {
+			return errors.New("metadata key must not be empty")
+		}
+		if strings.Contains(key, "=") {
+			return fmt.Errorf("metadata key %q must not contain '='", key)
+		}
+	}
+	return nil
+}
+
+// metadataArgs builds -metadata arguments for the tags in sorted key order.
+// streamTitle is used as the title unless the tags set their own.
+func metadataArgs(streamTitle string, metadata map[string]string) []string {
+	tags := make(map[string]string, len(metadata)+1)
+	if streamTitle != "" {
+		tags["title"] = streamTitle
+	}
+	for key, value := range metadata {
+		tags[key] = value
+	}
+
+	var args []string
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		args = append(args, "-metadata", key+"="+tags[key])
+	}
+	return args
+}
@@ -0,0 +1,26 @@
+package streammanager
+
+import "testing"
+
+func TestValidateMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  bool
+	}{
+		{name: "none", metadata: nil},
+		{name: "title and artist", metadata: map[string]string{"title": "Song", "artist": "Band"}},
+		{name: "empty value", metadata: map[string]string{"comment": ""}},
+		{name: "empty key", metadata: map[string]string{" ": "Song"}, wantErr: true},
+		{name: "key with equals", metadata: map[string]string{"a=b": "Song"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMetadata(tt.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package streammanager
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// StreamDestination is an RTMP destination published to alongside
+// Config.Destination, with its own credentials
+type StreamDestination struct {
+	URL       string `json:"url"`
+	StreamKey string `json:"streamKey"` // Appended to URL as the final path segment
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+}
+
+// validateDestinations checks that every extra destination has a URL
+func validateDestinations(destinations []StreamDestination) error {
+	for i, d := range destinations {
+		if d.URL == "" {
+			return fmt.Errorf("destination %d is missing a url", i+1)
+		}
+	}
+	return nil
+}
+
+// destinationURLs returns the full URL of every destination, the primary
+// first, with stream keys and credentials applied
+func destinationURLs(cfg ffmpegArgs) []string {
+	var urls []string
+	if cfg.destination != "" {
+		urls = append(urls, buildDestination(joinStreamKey(cfg.destination, cfg.streamKey), cfg.username, cfg.password))
+	}
+	for _, d := range cfg.destinations {
+		urls = append(urls, buildDestination(joinStreamKey(d.URL, d.StreamKey), d.Username, d.Password))
+	}
+	return urls
+}
+
+// teeEscaper escapes characters special to the tee muxer's output list
+var teeEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `|`, `\|`)
+
+// buildTeeOutput builds the tee muxer output that publishes to every URL as
+// FLV. A destination that fails is dropped while the others carry on.
+func buildTeeOutput(urls []string) string {
+	slaves := make([]string, len(urls))
+	for i, url := range urls {
+		slaves[i] = "[f=flv:flvflags=no_duration_filesize:onfail=ignore]" + teeEscaper.Replace(url)
+	}
+	return strings.Join(slaves, "|")
+}
+
+// configSecrets returns every credential and stream key in cfg, including
+// keys written into destination URLs, to redact from logged commands
+func configSecrets(cfg Config) []string {
+	secrets := []string{cfg.Password, cfg.StreamKey, urlStreamKey(cfg.Destination)}
+	for _, d := range cfg.Destinations {
+		secrets = append(secrets, d.Password, d.StreamKey, urlStreamKey(d.URL))
+	}
+	return secrets
+}
+
+// teeFailurePattern matches the tee muxer dropping a failed destination
+var teeFailurePattern = regexp.MustCompile(`Slave muxer #(\d+) failed:?\s*(.*)`)
+
+// teeFailureWriter watches the streaming ffmpeg's stderr for destinations
+// the tee muxer drops and reports each by index with ffmpeg's reason
+type teeFailureWriter struct {
+	mu     sync.Mutex
+	buf    []byte
+	failed func(index int, reason string)
+}
+
+func (w *teeFailureWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+		if m := teeFailurePattern.FindStringSubmatch(line); m != nil {
+			if index, err := strconv.Atoi(m[1]); err == nil {
+				w.failed(index, strings.TrimSpace(m[2]))
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// destinationNames returns the URLs of the configured destinations without
+// stream keys or credentials, the primary first. The caller must hold s.mu.
+func (s *StreamManager) destinationNames() []string {
+	var names []string
+	if s.config.Destination != "" {
+		names = append(names, s.config.Destination)
+	}
+	for _, d := range s.config.Destinations {
+		names = append(names, d.URL)
+	}
+	return names
+}
+
+// markDestinationFailed records that the tee muxer dropped the destination
+// at index
+func (s *StreamManager) markDestinationFailed(index int, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.destinationErrors == nil {
+		s.destinationErrors = make(map[int]string)
+	}
+	if reason == "" {
+		reason = "destination failed"
+	}
+	s.destinationErrors[index] = reason
+}
+
+// destinationStatus reports whether each destination of a multistream is
+// still live. The caller must hold s.mu.
+func (s *StreamManager) destinationStatus() []map[string]any {
+	names := s.destinationNames()
+	status := make([]map[string]any, len(names))
+	for i, name := range names {
+		status[i] = map[string]any{"url": name, "live": true}
+		if reason, failed := s.destinationErrors[i]; failed {
+			status[i]["live"] = false
+			status[i]["error"] = reason
+		}
+	}
+	return status
+}
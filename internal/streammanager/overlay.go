@@ -0,0 +1,114 @@
+package streammanager
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var overlayPositions = []string{"top-left", "top-right", "bottom-left", "bottom-right"}
+
+// defaultWatermarkPosition keeps the watermark clear of the filename overlay
+// and live text, which default to the bottom corners
+const defaultWatermarkPosition = "top-right"
+
+// validateOverlay checks the overlay position and font size, and the
+// watermark. An empty position uses the default, bottom-right.
+func validateOverlay(overlay OverlaySettings) error {
+	if err := validateOverlayPosition(overlay.Position); err != nil {
+		return err
+	}
+	if overlay.FontSize < 0 {
+		return fmt.Errorf("overlay font size must not be negative, got %d", overlay.FontSize)
+	}
+	return validateWatermark(overlay)
+}
+
+// validateWatermark checks that the watermark is an image and its position
+func validateWatermark(overlay OverlaySettings) error {
+	if err := validateImageFile(overlay.WatermarkFile); err != nil {
+		return fmt.Errorf("invalid watermark: %w", err)
+	}
+	return validateOverlayPosition(overlay.WatermarkPosition)
+}
+
+func validateOverlayPosition(position string) error {
+	if position != "" && !slices.Contains(overlayPositions, position) {
+		return fmt.Errorf("unsupported overlay position %q, must be one of %s",
+			position, strings.Join(overlayPositions, ", "))
+	}
+	return nil
+}
+
+// buildWatermarkOverlay constructs the filters that burn the watermark image
+// into the picture. It reads the image with the movie source and overlays it
+// on the chain so far, so it fits between the other filters of a -vf chain.
+// The overlay keeps the image's transparency and repeats its single frame.
+func buildWatermarkOverlay(watermarkFile, position string) string {
+	if position == "" {
+		position = defaultWatermarkPosition
+	}
+
+	var x, y string
+	switch position {
+	case "top-left":
+		x, y = "10", "10"
+	case "bottom-left":
+		x, y = "10", "H-h-10"
+	case "bottom-right":
+		x, y = "W-w-10", "H-h-10"
+	default:
+		x, y = "W-w-10", "10"
+	}
+
+	return fmt.Sprintf("null[watermark_base];movie=%s[watermark];[watermark_base][watermark]overlay=x=%s:y=%s",
+		escapeFilterArg(watermarkFile), x, y)
+}
+
+// mergeOverlay returns overlay with its unset fields other than ShowFilename
+// taken from def, or def itself when overlay is nil
+func mergeOverlay(overlay *OverlaySettings, def OverlaySettings) OverlaySettings {
+	if overlay == nil {
+		return def
+	}
+	merged := *overlay
+	if merged.Position == "" {
+		merged.Position = def.Position
+	}
+	if merged.FontSize == 0 {
+		merged.FontSize = def.FontSize
+	}
+	if merged.WatermarkFile == "" {
+		merged.WatermarkFile = def.WatermarkFile
+	}
+	if merged.WatermarkPosition == "" {
+		merged.WatermarkPosition = def.WatermarkPosition
+	}
+	return merged
+}
+
+// SetDefaultOverlay sets the overlay applied to entries enqueued without one
+func (s *StreamManager) SetDefaultOverlay(overlay OverlaySettings) error {
+	if err := validateOverlay(overlay); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultOverlay = overlay
+	return nil
+}
+
+// DefaultOverlay returns the overlay applied to entries enqueued without one
+func (s *StreamManager) DefaultOverlay() OverlaySettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.defaultOverlay
+}
+
+// EntryOverlay returns the overlay to enqueue an entry with: the default
+// when the entry sets none, otherwise the entry's own with its unset fields
+// taken from the default
+func (s *StreamManager) EntryOverlay(overlay *OverlaySettings) OverlaySettings {
+	return mergeOverlay(overlay, s.DefaultOverlay())
+}
@@ -0,0 +1,130 @@
+package streammanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeOverlay(t *testing.T) {
+	def := OverlaySettings{ShowFilename: true, Position: "top-left", FontSize: 32}
+
+	tests := []struct {
+		name    string
+		overlay *OverlaySettings
+		want    OverlaySettings
+	}{
+		{name: "omitted", want: def},
+		{
+			name:    "entry overrides",
+			overlay: &OverlaySettings{ShowFilename: true, Position: "bottom-right", FontSize: 20},
+			want:    OverlaySettings{ShowFilename: true, Position: "bottom-right", FontSize: 20},
+		},
+		{
+			name:    "unset fields from default",
+			overlay: &OverlaySettings{ShowFilename: true},
+			want:    OverlaySettings{ShowFilename: true, Position: "top-left", FontSize: 32},
+		},
+		{
+			name:    "entry disables filename",
+			overlay: &OverlaySettings{},
+			want:    OverlaySettings{Position: "top-left", FontSize: 32},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeOverlay(tt.overlay, def); got != tt.want {
+				t.Errorf("mergeOverlay() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetDefaultOverlay(t *testing.T) {
+	sm := newTestStreamManager(t)
+
+	if got := sm.EntryOverlay(nil); got != (OverlaySettings{}) {
+		t.Errorf("EntryOverlay(nil) without a default = %+v, want none", got)
+	}
+
+	def := OverlaySettings{ShowFilename: true, Position: "top-right", FontSize: 24}
+	if err := sm.SetDefaultOverlay(def); err != nil {
+		t.Fatalf("SetDefaultOverlay() error = %v", err)
+	}
+	if got := sm.DefaultOverlay(); got != def {
+		t.Errorf("DefaultOverlay() = %+v, want %+v", got, def)
+	}
+	if got := sm.EntryOverlay(nil); got != def {
+		t.Errorf("EntryOverlay(nil) = %+v, want %+v", got, def)
+	}
+
+	for _, invalid := range []OverlaySettings{{Position: "middle"}, {FontSize: -1}} {
+		if err := sm.SetDefaultOverlay(invalid); err == nil {
+			t.Errorf("SetDefaultOverlay(%+v) = nil, want error", invalid)
+		}
+	}
+	if got := sm.DefaultOverlay(); got != def {
+		t.Errorf("DefaultOverlay() after invalid updates = %+v, want %+v", got, def)
+	}
+}
+
+func TestBuildWatermarkOverlay(t *testing.T) {
+	tests := []struct {
+		position string
+		expected string
+	}{
+		{position: "", expected: "overlay=x=W-w-10:y=10"},
+		{position: "top-left", expected: "overlay=x=10:y=10"},
+		{position: "top-right", expected: "overlay=x=W-w-10:y=10"},
+		{position: "bottom-left", expected: "overlay=x=10:y=H-h-10"},
+		{position: "bottom-right", expected: "overlay=x=W-w-10:y=H-h-10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.position, func(t *testing.T) {
+			got := buildWatermarkOverlay("/my logos/it's.png", tt.position)
+			expected := `null[watermark_base];movie='/my logos/it\'\''s.png'[watermark];[watermark_base][watermark]` + tt.expected
+			if got != expected {
+				t.Errorf("buildWatermarkOverlay() = %s, want %s", got, expected)
+			}
+		})
+	}
+
+	// Alone, the watermark takes the -vf input and gives its output
+	got := buildVideoFilter(ffmpegArgs{source: "/path/to/video.mp4", overlay: OverlaySettings{WatermarkFile: "/logo.png"}})
+	if expected := "null[watermark_base];movie='/logo.png'[watermark];[watermark_base][watermark]overlay=x=W-w-10:y=10"; got != expected {
+		t.Errorf("buildVideoFilter() = %s, want %s", got, expected)
+	}
+}
+
+func TestValidateWatermark(t *testing.T) {
+	dir := t.TempDir()
+	logo, text := filepath.Join(dir, "logo.png"), filepath.Join(dir, "logo.txt")
+	for _, file := range []string{logo, text} {
+		if err := os.WriteFile(file, []byte("logo"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		overlay OverlaySettings
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "image", overlay: OverlaySettings{WatermarkFile: logo, WatermarkPosition: "bottom-left"}},
+		{name: "missing file", overlay: OverlaySettings{WatermarkFile: "/missing/logo.png"}, wantErr: true},
+		{name: "not an image", overlay: OverlaySettings{WatermarkFile: text}, wantErr: true},
+		{name: "unsupported position", overlay: OverlaySettings{WatermarkFile: logo, WatermarkPosition: "center"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWatermark(tt.overlay)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateWatermark() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
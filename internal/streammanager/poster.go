@@ -0,0 +1,104 @@
+package streammanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// defaultPosterOffset is where the poster frame is taken from for entries
+// without a start timestamp, skipping past black lead-in frames
+const defaultPosterOffset = "5"
+
+// Poster returns a JPEG frame from the queued or playing entry at its start
+// timestamp. It reports false when no entry has the id. Posters are cached
+// for as long as the entry is queued or playing.
+func (s *StreamManager) Poster(ctx context.Context, id string) ([]byte, bool, error) {
+	s.mu.Lock()
+	e, found := s.findEntry(id)
+	s.prunePosters()
+	cached, ok := s.posters[id]
+	s.mu.Unlock()
+
+	if !found {
+		return nil, false, nil
+	}
+	if ok {
+		return cached, true, nil
+	}
+
+	offset := e.StartTimestamp
+	if offset == "" {
+		offset = defaultPosterOffset
+	}
+
+	poster, err := extractFrame(ctx, e.File, offset)
+	if err == nil && len(poster) == 0 && e.StartTimestamp == "" {
+		// Clips shorter than the default offset yield no frame
+		poster, err = extractFrame(ctx, e.File, "0")
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	if len(poster) == 0 {
+		return nil, true, fmt.Errorf("no frame found at %s", offset)
+	}
+
+	s.mu.Lock()
+	if s.posters == nil {
+		s.posters = make(map[string][]byte)
+	}
+	s.posters[id] = poster
+	s.mu.Unlock()
+
+	return poster, true, nil
+}
+
+// findEntry returns the queued or playing entry with id. The caller must
+// hold s.mu.
+func (s *StreamManager) findEntry(id string) (entry, bool) {
+	if s.currentEntry != nil && s.currentEntry.ID == id {
+		return *s.currentEntry, true
+	}
+	for _, e := range s.queue {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return entry{}, false
+}
+
+// prunePosters drops cached posters for entries that are no longer queued or
+// playing. The caller must hold s.mu.
+func (s *StreamManager) prunePosters() {
+	for id := range s.posters {
+		if _, ok := s.findEntry(id); !ok {
+			delete(s.posters, id)
+		}
+	}
+}
+
+// extractFrame decodes a single frame at offset and encodes it as JPEG
+func extractFrame(ctx context.Context, file, offset string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-ss", offset,
+		"-i", file,
+		"-frames:v", "1",
+		"-f", "image2", "-c:v", "mjpeg",
+		"pipe:1")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ffmpeg failed: %w\nFFmpeg stderr: %s", err, msg)
+		}
+		return nil, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -6,9 +6,14 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// progressSubscriberBuffer is how many updates a progress subscriber may fall
+// behind by before updates to it are dropped
+const progressSubscriberBuffer = 16
+
 type progressData struct {
 	Frame      int64     `json:"frame"`
 	Fps        float64   `json:"fps"`
@@ -113,3 +118,131 @@ func parseProgress(ctx context.Context, r io.Reader, out chan progressData) {
 		}
 	}
 }
+
+// defaultStallThreshold is how long output time may stay still before the
+// stream is reported as stalled when Config.StallThreshold is unset
+const defaultStallThreshold = 10 * time.Second
+
+// minKeepingUpSpeed is the encode speed below which the encoder is considered
+// unable to keep up with realtime, allowing for jitter around 1.0x
+const minKeepingUpSpeed = 0.97
+
+// parseSpeed parses ffmpeg's speed value (e.g. "1.02x") into a factor of
+// realtime. It reports false for values such as "N/A".
+func parseSpeed(speed string) (float64, bool) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(speed), "x"), 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// trackProgress drains every update from in, recording the latest progress
+// and when the output time last advanced, and forwards each update to out,
+// dropping updates when out is full. It runs for the whole stream so progress
+// stays current even when nothing reads out.
+func (s *StreamManager) trackProgress(ctx context.Context, in <-chan progressData, out chan progressData) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data := <-in:
+			s.observeProgress(data)
+			select {
+			case out <- data:
+			default:
+			}
+		}
+	}
+}
+
+// observeProgress caches the progress update and updates the stall
+// tracking state from it
+func (s *StreamManager) observeProgress(data progressData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latest = &data
+	if s.session != nil {
+		s.session.observe(data)
+	}
+	for ch := range s.progressSubs {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+
+	if data.OutTimeUs > s.lastOutTimeUs {
+		s.lastOutTimeUs = data.OutTimeUs
+		s.lastAdvance = s.clock.Now()
+	}
+	if speed, ok := parseSpeed(data.Speed); ok {
+		s.speedFactor = speed
+	}
+}
+
+// SubscribeProgress returns a channel that receives every progress update
+// from now on and a function that ends the subscription. Unlike
+// GetProgressChan, each subscriber sees every update; updates are dropped for
+// one that falls behind.
+func (s *StreamManager) SubscribeProgress() (<-chan progressData, func()) {
+	ch := make(chan progressData, progressSubscriberBuffer)
+
+	s.mu.Lock()
+	if s.progressSubs == nil {
+		s.progressSubs = make(map[chan progressData]struct{})
+	}
+	s.progressSubs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.progressSubs, ch)
+			s.mu.Unlock()
+		})
+	}
+}
+
+// resetStallTracking marks now as the last time output advanced. The caller
+// must hold s.mu.
+func (s *StreamManager) resetStallTracking() {
+	s.lastOutTimeUs = 0
+	s.lastAdvance = s.clock.Now()
+	s.speedFactor = 0
+}
+
+// stallStatus returns the seconds since output time last advanced and whether
+// that exceeds the configured threshold. The caller must hold s.mu.
+func (s *StreamManager) stallStatus() (float64, bool) {
+	if s.lastAdvance.IsZero() {
+		return 0, false
+	}
+
+	threshold := defaultStallThreshold
+	if s.config.StallThreshold > 0 {
+		threshold = time.Duration(s.config.StallThreshold) * time.Second
+	}
+
+	stalledFor := s.clock.Now().Sub(s.lastAdvance)
+	return stalledFor.Seconds(), stalledFor > threshold
+}
+
+// StallStatus returns the seconds since output time last advanced and whether
+// the stream is considered stalled
+func (s *StreamManager) StallStatus() (float64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stallStatus()
+}
+
+// SpeedStatus returns the latest encode speed as a factor of realtime and
+// whether it is fast enough to keep up. Both are zero until ffmpeg reports a
+// speed.
+func (s *StreamManager) SpeedStatus() (float64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.speedFactor, s.speedFactor >= minKeepingUpSpeed
+}
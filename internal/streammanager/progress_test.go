@@ -396,3 +396,157 @@ func TestParseProgressReaderError(t *testing.T) {
 		// Expected behavior
 	}
 }
+
+func TestStallTracking(t *testing.T) {
+	sm := newTestStreamManager(t)
+	clock := newFakeClock()
+	sm.clock = clock
+	sm.config.StallThreshold = 5
+
+	if _, stalled := sm.StallStatus(); stalled {
+		t.Fatal("StallStatus() reported stalled before any progress")
+	}
+
+	sm.resetStallTracking()
+	sm.observeProgress(progressData{OutTimeUs: 1_000_000})
+	clock.Advance(3 * time.Second)
+	if seconds, stalled := sm.StallStatus(); stalled || seconds != 3 {
+		t.Errorf("StallStatus() = %v, %v; want 3s and not stalled", seconds, stalled)
+	}
+
+	// Output time that does not advance must not reset the stall timer
+	sm.observeProgress(progressData{OutTimeUs: 1_000_000})
+	clock.Advance(3 * time.Second)
+	if seconds, stalled := sm.StallStatus(); !stalled || seconds != 6 {
+		t.Errorf("StallStatus() = %v, %v; want 6s and stalled", seconds, stalled)
+	}
+
+	sm.observeProgress(progressData{OutTimeUs: 2_000_000})
+	if seconds, stalled := sm.StallStatus(); stalled || seconds != 0 {
+		t.Errorf("StallStatus() = %v, %v after progress advanced; want reset", seconds, stalled)
+	}
+}
+
+func TestParseSpeed(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+		ok       bool
+	}{
+		{input: "1.5x", expected: 1.5, ok: true},
+		{input: "0.95x", expected: 0.95, ok: true},
+		{input: " 1x", expected: 1, ok: true},
+		{input: "N/A"},
+		{input: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := parseSpeed(tt.input)
+			if ok != tt.ok || got != tt.expected {
+				t.Errorf("parseSpeed(%q) = %v, %v; want %v, %v", tt.input, got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSpeedStatus(t *testing.T) {
+	sm := newTestStreamManager(t)
+
+	if speed, keepingUp := sm.SpeedStatus(); speed != 0 || keepingUp {
+		t.Errorf("SpeedStatus() = %v, %v before progress; want 0, false", speed, keepingUp)
+	}
+
+	sm.observeProgress(progressData{Speed: "0.98x"})
+	if _, keepingUp := sm.SpeedStatus(); !keepingUp {
+		t.Error("SpeedStatus() not keeping up at 0.98x")
+	}
+
+	sm.observeProgress(progressData{Speed: "0.8x"})
+	if speed, keepingUp := sm.SpeedStatus(); speed != 0.8 || keepingUp {
+		t.Errorf("SpeedStatus() = %v, %v; want 0.8, false", speed, keepingUp)
+	}
+
+	// Unparseable speeds keep the last known value
+	sm.observeProgress(progressData{Speed: "N/A"})
+	if speed, _ := sm.SpeedStatus(); speed != 0.8 {
+		t.Errorf("SpeedStatus() = %v after N/A; want 0.8", speed)
+	}
+}
+
+func TestLatestProgressWithoutConsumer(t *testing.T) {
+	sm := newTestStreamManager(t)
+
+	if _, ok := sm.GetLatestProgress(); ok {
+		t.Fatal("GetLatestProgress() reported progress before any update")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Nothing reads out, so it fills up long before the last update
+	in := make(chan progressData)
+	out := make(chan progressData, 1)
+	go sm.trackProgress(ctx, in, out)
+
+	const updates = 150
+	for i := 1; i <= updates; i++ {
+		in <- progressData{OutTimeUs: int64(i)}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		latest, ok := sm.GetLatestProgress()
+		if ok && latest.OutTimeUs == updates {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GetLatestProgress() = %+v, %v; want the last update", latest, ok)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Reading the latest progress must not consume it
+	if latest, ok := sm.GetLatestProgress(); !ok || latest.OutTimeUs != updates {
+		t.Errorf("GetLatestProgress() = %+v, %v on second read; want the last update", latest, ok)
+	}
+}
+
+func TestSubscribeProgress(t *testing.T) {
+	sm := newTestStreamManager(t)
+
+	first, unsubscribeFirst := sm.SubscribeProgress()
+	second, unsubscribeSecond := sm.SubscribeProgress()
+	defer unsubscribeSecond()
+
+	sm.observeProgress(progressData{OutTimeUs: 1})
+
+	// Every subscriber sees every update
+	for i, ch := range []<-chan progressData{first, second} {
+		select {
+		case data := <-ch:
+			if data.OutTimeUs != 1 {
+				t.Errorf("subscriber %d received OutTimeUs %d; want 1", i, data.OutTimeUs)
+			}
+		default:
+			t.Errorf("subscriber %d received no update", i)
+		}
+	}
+
+	unsubscribeFirst()
+	unsubscribeFirst()
+	sm.observeProgress(progressData{OutTimeUs: 2})
+	select {
+	case data := <-first:
+		t.Errorf("unsubscribed channel received %+v", data)
+	default:
+	}
+	if data := <-second; data.OutTimeUs != 2 {
+		t.Errorf("remaining subscriber received OutTimeUs %d; want 2", data.OutTimeUs)
+	}
+
+	// A subscriber that falls behind never blocks progress tracking
+	for i := range progressSubscriberBuffer * 2 {
+		sm.observeProgress(progressData{OutTimeUs: int64(i)})
+	}
+}
@@ -0,0 +1,51 @@
+package streammanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// recordExtensions are the containers a recording can be written as
+var recordExtensions = []string{".ts", ".mp4", ".mkv", ".flv"}
+
+// validateRecordPath checks that the recording can be created: a supported
+// container, a writable directory and no existing file to overwrite. An empty
+// path records nothing.
+func validateRecordPath(recordPath string) error {
+	if recordPath == "" {
+		return nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(recordPath))
+	if !slices.Contains(recordExtensions, ext) {
+		return fmt.Errorf("unsupported recording format %q, must be one of %s", ext, strings.Join(recordExtensions, ", "))
+	}
+
+	if _, err := os.Stat(recordPath); err == nil {
+		return fmt.Errorf("recording file already exists: %s", recordPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to check recording file: %w", err)
+	}
+
+	probe, err := os.CreateTemp(filepath.Dir(recordPath), ".streammanager-record-*")
+	if err != nil {
+		return fmt.Errorf("recording directory is not writable: %w", err)
+	}
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+	return nil
+}
+
+// recordingStatus describes the recording of the current stream. The caller
+// must hold s.mu.
+func (s *StreamManager) recordingStatus() map[string]any {
+	status := map[string]any{"path": s.config.RecordPath}
+	if info, err := os.Stat(s.config.RecordPath); err == nil {
+		status["bytes"] = info.Size()
+	}
+	return status
+}
@@ -0,0 +1,44 @@
+package streammanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateRecordPath(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.ts")
+	if err := os.WriteFile(existing, []byte("ts"), 0o644); err != nil {
+		t.Fatalf("failed to write existing recording: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		expectErr bool
+	}{
+		{name: "unset"},
+		{name: "new file", path: filepath.Join(dir, "show.mp4")},
+		{name: "unsupported format", path: filepath.Join(dir, "show.avi"), expectErr: true},
+		{name: "existing file", path: existing, expectErr: true},
+		{name: "missing directory", path: filepath.Join(dir, "missing", "show.ts"), expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRecordPath(tt.path)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("validateRecordPath(%q) error = %v, expectErr %v", tt.path, err, tt.expectErr)
+			}
+		})
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read recording directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("validateRecordPath() left %d files in the directory, want only the existing recording", len(entries))
+	}
+}
@@ -0,0 +1,134 @@
+package streammanager
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// sessionStats accumulates what a session played and how the output fared,
+// for the report. It is replaced when the next session starts.
+type sessionStats struct {
+	startedAt  time.Time
+	endedAt    time.Time
+	entries    []historyEntry
+	errors     []string
+	samples    int
+	fpsSum     float64
+	speedSum   float64
+	dropFrames int64
+	dupFrames  int64
+	totalBytes int64
+}
+
+// observe folds a progress update into the session. ffmpeg reports frame and
+// byte counters cumulatively, so the latest values are the totals.
+func (st *sessionStats) observe(data progressData) {
+	speed, ok := parseSpeed(data.Speed)
+	if ok {
+		st.samples++
+		st.fpsSum += data.Fps
+		st.speedSum += speed
+	}
+	st.dropFrames = max(st.dropFrames, data.DropFrames)
+	st.dupFrames = max(st.dupFrames, data.DupFrames)
+	st.totalBytes = max(st.totalBytes, data.TotalSize)
+}
+
+// reportEntry is a played entry in a session report
+type reportEntry struct {
+	historyEntry
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// Report summarizes a streaming session after the fact
+type Report struct {
+	StartedAt        time.Time      `json:"startedAt"`
+	EndedAt          *time.Time     `json:"endedAt,omitempty"`
+	Running          bool           `json:"running"`
+	DurationSeconds  float64        `json:"durationSeconds"`
+	Entries          []reportEntry  `json:"entries"`
+	Outcomes         map[string]int `json:"outcomes"`
+	AverageFps       float64        `json:"averageFps"`
+	AverageSpeed     float64        `json:"averageSpeed"`
+	DroppedFrames    int64          `json:"droppedFrames"`
+	DuplicatedFrames int64          `json:"duplicatedFrames"`
+	TotalBytes       int64          `json:"totalBytes"`
+	Errors           []string       `json:"errors"`
+}
+
+// startSession begins a new report, discarding the last one. The caller must
+// hold s.mu.
+func (s *StreamManager) startSession() {
+	s.session = &sessionStats{startedAt: s.clock.Now()}
+}
+
+// Report compiles the report of the current session, or the last one once it
+// has stopped. It reports false when no session has run yet.
+func (s *StreamManager) Report() (Report, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st := s.session
+	if st == nil {
+		return Report{}, false
+	}
+
+	r := Report{
+		StartedAt:        st.startedAt,
+		Running:          st.endedAt.IsZero(),
+		Entries:          make([]reportEntry, 0, len(st.entries)),
+		Outcomes:         make(map[string]int),
+		DroppedFrames:    st.dropFrames,
+		DuplicatedFrames: st.dupFrames,
+		TotalBytes:       st.totalBytes,
+		Errors:           append([]string{}, st.errors...),
+	}
+
+	end := s.clock.Now()
+	if !r.Running {
+		endedAt := st.endedAt
+		r.EndedAt = &endedAt
+		end = endedAt
+	}
+	r.DurationSeconds = end.Sub(st.startedAt).Seconds()
+
+	for _, h := range st.entries {
+		r.Entries = append(r.Entries, reportEntry{
+			historyEntry:    h,
+			DurationSeconds: h.EndedAt.Sub(h.StartedAt).Seconds(),
+		})
+		r.Outcomes[h.Outcome]++
+	}
+
+	if st.samples > 0 {
+		r.AverageFps = st.fpsSum / float64(st.samples)
+		r.AverageSpeed = st.speedSum / float64(st.samples)
+	}
+	return r, true
+}
+
+// WriteCSV writes the report's entries as CSV with a header row
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "file", "startedAt", "endedAt", "durationSeconds", "outcome", "error"}); err != nil {
+		return fmt.Errorf("failed to write report header: %w", err)
+	}
+	for _, e := range r.Entries {
+		if err := cw.Write([]string{
+			e.ID,
+			e.File,
+			e.StartedAt.Format(time.RFC3339),
+			e.EndedAt.Format(time.RFC3339),
+			strconv.FormatFloat(e.DurationSeconds, 'f', 3, 64),
+			e.Outcome,
+			e.Error,
+		}); err != nil {
+			return fmt.Errorf("failed to write report entry: %w", err)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
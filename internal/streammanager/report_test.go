@@ -0,0 +1,84 @@
+package streammanager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	sm := newTestStreamManager(t)
+	clock := newFakeClock()
+	sm.clock = clock
+
+	if _, ok := sm.Report(); ok {
+		t.Fatal("Report() = true before any session, want false")
+	}
+
+	if !sm.TryStart(Config{}) {
+		t.Fatal("TryStart() = false, want true")
+	}
+	sm.ctx = context.Background()
+
+	sm.observeProgress(progressData{Fps: 30, Speed: "1.0x", TotalSize: 1000})
+	sm.observeProgress(progressData{Fps: 20, Speed: "0.5x", TotalSize: 3000, DropFrames: 4, DupFrames: 1})
+
+	sm.beginEntry(entry{ID: "1", File: "a.mp4"}, 1)
+	clock.Advance(90 * time.Second)
+	sm.finishEntry(entry{ID: "1", File: "a.mp4"}, nil)
+
+	sm.beginEntry(entry{ID: "2", File: "b.mp4"}, 1)
+	clock.Advance(10 * time.Second)
+	sm.finishEntry(entry{ID: "2", File: "b.mp4"}, errors.New("bad file"))
+	sm.setError("FFmpeg processing failed for b.mp4: bad file")
+
+	report, ok := sm.Report()
+	if !ok {
+		t.Fatal("Report() = false during a session, want true")
+	}
+	if !report.Running || report.EndedAt != nil {
+		t.Errorf("Report() running = %v, endedAt = %v during a session", report.Running, report.EndedAt)
+	}
+	if report.DurationSeconds != 100 {
+		t.Errorf("DurationSeconds = %v, want 100", report.DurationSeconds)
+	}
+	if len(report.Entries) != 2 || report.Entries[0].DurationSeconds != 90 {
+		t.Fatalf("Entries = %+v, want two with the first lasting 90s", report.Entries)
+	}
+	if report.Outcomes[outcomeCompleted] != 1 || report.Outcomes[outcomeFailed] != 1 {
+		t.Errorf("Outcomes = %v, want one completed and one failed", report.Outcomes)
+	}
+	if report.AverageFps != 25 || report.AverageSpeed != 0.75 {
+		t.Errorf("AverageFps = %v, AverageSpeed = %v, want 25 and 0.75", report.AverageFps, report.AverageSpeed)
+	}
+	if report.DroppedFrames != 4 || report.DuplicatedFrames != 1 || report.TotalBytes != 3000 {
+		t.Errorf("counters = %d dropped, %d duplicated, %d bytes, want 4, 1 and 3000",
+			report.DroppedFrames, report.DuplicatedFrames, report.TotalBytes)
+	}
+	if len(report.Errors) != 1 {
+		t.Errorf("Errors = %v, want one", report.Errors)
+	}
+
+	var csv strings.Builder
+	if err := report.WriteCSV(&csv); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "id,file,") || !strings.HasSuffix(lines[2], ",failed,bad file") {
+		t.Errorf("WriteCSV() = %q, want a header and two entries", csv.String())
+	}
+
+	// The report outlives the session until the next one starts
+	sm.cleanup()
+	report, _ = sm.Report()
+	if report.Running || report.EndedAt == nil {
+		t.Error("Report() still running after the session ended")
+	}
+
+	sm.TryStart(Config{})
+	if report, _ := sm.Report(); len(report.Entries) != 0 {
+		t.Errorf("Report() kept %d entries into a new session, want 0", len(report.Entries))
+	}
+}
@@ -0,0 +1,62 @@
+package streammanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// validateStartPaused checks that there is a slate to stand by on
+func validateStartPaused(startPaused bool, slateImage string) error {
+	if !startPaused {
+		return nil
+	}
+	if slateImage == "" {
+		return errors.New("start paused requires a slate image")
+	}
+	if err := validateImageFile(slateImage); err != nil {
+		return fmt.Errorf("invalid slate image: %w", err)
+	}
+	return nil
+}
+
+// standBy streams the slate to the connected destination until GoLive is
+// called or ctx is done. It returns immediately unless the stream was
+// started paused.
+func (s *StreamManager) standBy(ctx context.Context) {
+	s.mu.RLock()
+	standby := s.standby
+	s.mu.RUnlock()
+
+	if !standby {
+		return
+	}
+
+	s.logger.Info("Standing by on the slate until go-live")
+	if !s.slateUntil(ctx, s.goLive) {
+		// The slate ended early; keep standing by on the idle FIFO
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.goLive:
+		}
+	}
+	s.logger.Info("Going live")
+}
+
+// GoLive ends the standby of a stream started paused, starting the intro
+// and queue. It reports false when the stream is not standing by.
+func (s *StreamManager) GoLive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running || !s.standby {
+		return false
+	}
+	s.standby = false
+	select {
+	case s.goLive <- struct{}{}:
+	default:
+	}
+	return true
+}
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -19,48 +21,153 @@ import (
 )
 
 type entry struct {
-	ID             string          `json:"id"`
-	File           string          `json:"file"`
-	Overlay        OverlaySettings `json:"overlay"`
-	StartTimestamp string          `json:"startTimestamp,omitempty"` // Format: HH:MM:SS or seconds
-	SubtitleFile   string          `json:"subtitleFile,omitempty"`   // Path to subtitle file
+	ID         string    `json:"id"`
+	File       string    `json:"file"`
+	State      string    `json:"state"`      // Where the entry is until it finishes, see stateQueued
+	StateSince time.Time `json:"stateSince"` // When the entry entered State
+	EntryOptions
+}
+
+// Lifecycle states of an entry. Once it finishes, the history records how
+// with its outcome.
+const (
+	stateQueued        = "queued"        // Waiting in the queue
+	statePreprocessing = "preprocessing" // Being validated and probed, or waiting for an encode slot
+	stateStreaming     = "streaming"     // ffmpeg is feeding it into the FIFO
+)
+
+// EntryOptions holds the per-entry playback settings supplied at enqueue
+type EntryOptions struct {
+	Overlay          OverlaySettings   `json:"overlay"`
+	StartTimestamp   string            `json:"startTimestamp,omitempty"`   // Format: HH:MM:SS or seconds
+	EndTimestamp     string            `json:"endTimestamp,omitempty"`     // Stop at this position in the file, same format as StartTimestamp
+	SubtitleFile     string            `json:"subtitleFile,omitempty"`     // Path to subtitle file
+	SubtitleEncoding string            `json:"subtitleEncoding,omitempty"` // Character encoding of SubtitleFile, e.g. "CP1252", detected when empty
+	Mute             bool              `json:"mute,omitempty"`             // Drop the audio track entirely
+	FadeIn           float64           `json:"fadeIn,omitempty"`           // Seconds to fade in from black/silence
+	FadeOut          float64           `json:"fadeOut,omitempty"`          // Seconds to fade out to black/silence
+	Volume           float64           `json:"volume,omitempty"`           // Audio amplitude multiplier, 2 doubles it, 0 or 1 leaves it unchanged
+	ImageFile        string            `json:"imageFile,omitempty"`        // Still image shown as the video for an audio-only file
+	InputFormat      string            `json:"inputFormat,omitempty"`      // ffmpeg demuxer for sources it cannot detect, e.g. "h264"
+	Metadata         map[string]string `json:"metadata,omitempty"`         // Tags set on this entry's output, e.g. "title" and "artist"
+	Repeat           int               `json:"repeat,omitempty"`           // Times to play the entry in a row, 0 or 1 plays it once
 }
 
 type OverlaySettings struct {
-	ShowFilename bool   `json:"showFilename"`
-	Position     string `json:"position"`
-	FontSize     int    `json:"fontSize"`
+	ShowFilename      bool   `json:"showFilename"`
+	Position          string `json:"position"`
+	FontSize          int    `json:"fontSize"`
+	WatermarkFile     string `json:"watermarkFile,omitempty"`     // Image burned in over the video, transparency included
+	WatermarkPosition string `json:"watermarkPosition,omitempty"` // Corner of the watermark, default top-right
 }
 
 type Config struct {
-	Destination      string `json:"destination"`
-	MaxBitrate       string `json:"maxBitrate"`
-	Username         string `json:"username"`
-	Password         string `json:"password"`
-	Encoder          string `json:"encoder"`
-	Preset           string `json:"preset"`
-	RTMPAddr         string `json:"rtmpAddr"`
-	LogLevel         string `json:"logLevel"`
-	KeyframeInterval string `json:"keyframeInterval"` // GOP size in frames, e.g. "60"
+	Destination        string `json:"destination"`
+	RecordPath         string `json:"recordPath"`     // Also write the output to this local file, or only to it without a Destination
+	StreamKey          string `json:"streamKey"`      // Appended to Destination as the final path segment
+	ConnectTimeout     int    `json:"connectTimeout"` // Seconds to wait on the destination before failing, 0 waits forever
+	NoRealtime         bool   `json:"noRealtime"`     // Stream as fast as possible instead of at realtime, for transcoding to a file or fast sink
+	MaxMuxingQueue     int    `json:"maxMuxingQueue"` // Packets the streaming ffmpeg may buffer per output stream, default 1024
+	TimestampMode      string `json:"timestampMode"`  // ignore (default), generate or passthrough for the FIFO's timestamps, see TimestampIgnore
+	MaxBitrate         string `json:"maxBitrate"`
+	TargetBitrate      string `json:"targetBitrate"` // Average video bitrate, peaking up to MaxBitrate
+	MinBitrate         string `json:"minBitrate"`    // Floor for the video bitrate
+	BufSize            string `json:"bufSize"`       // VBV buffer size, default two seconds at MaxBitrate when TargetBitrate is set
+	Username           string `json:"username"`
+	Password           string `json:"password"`
+	Encoder            string `json:"encoder"`
+	Preset             string `json:"preset"`
+	RTMPAddr           string `json:"rtmpAddr"`
+	LogLevel           string `json:"logLevel"`           // ffmpeg -loglevel for this stream's ffmpeg processes only, default error
+	PreprocessLogLevel string `json:"preprocessLogLevel"` // Overrides LogLevel for the preprocessing ffmpeg
+	StreamLogLevel     string `json:"streamLogLevel"`     // Overrides LogLevel for the streaming ffmpeg
+	StreamTitle        string `json:"streamTitle"`        // Title tag of the output, and of entries without their own "title" metadata
+	KeyframeInterval   string `json:"keyframeInterval"`   // GOP size in frames, e.g. "60"
+	BFrames            string `json:"bFrames"`            // B-frames between reference frames, "0" for low latency, default is the encoder's
+	RefFrames          string `json:"refFrames"`          // Reference frames, 1 to 16, default is the encoder's
+	NoSceneCut         bool   `json:"noSceneCut"`         // Disable scene-cut keyframes for a strictly fixed GOP
+	FastStart          int    `json:"fastStart"`          // Seconds at the start of the stream with a keyframe every second, so early viewers join quickly, 0 disables
+	AutoRotate         bool   `json:"autoRotate"`         // Turn videos with rotation metadata upright, leaving no rotation in the output
+	Profile            string `json:"profile"`            // H264 profile, e.g. "baseline", default is the encoder's
+	Level              string `json:"level"`              // H264 level, e.g. "3.1", default is the encoder's
+	PixelFormat        string `json:"pixelFormat"`        // Output pixel format, default yuv420p
+	AudioSampleRate    int    `json:"audioSampleRate"`    // Resample all audio to this rate in Hz, 0 keeps each source's rate
+	NormalizeAudio     bool   `json:"normalizeAudio"`     // Normalize loudness to -16 LUFS integrated, -1.5 dBTP true peak and 11 LU range with loudnorm
+	SilentAudio        bool   `json:"silentAudio"`        // Give entries without audio, or muted ones, a silent track so the output never loses audio
+	OutputSize         string `json:"outputSize"`         // Scale and letterbox every entry to WxH, e.g. "1280x720", empty keeps each source's size
+	OutputFrameRate    int    `json:"outputFrameRate"`    // Convert every entry to this frame rate, 0 keeps each source's rate
+	OverlayFallback    bool   `json:"overlayFallback"`    // Retry a failed entry without overlays/subtitles
+	ASSOriginalSize    bool   `json:"assOriginalSize"`    // Render ASS/SSA subtitles relative to their script resolution
+	StallThreshold     int    `json:"stallThreshold"`     // Seconds without output progress before reporting a stall, default 10
+	RequireQueue       bool   `json:"requireQueue"`       // Refuse to start while the queue is empty
+	EndOfQueueAction   string `json:"endOfQueueAction"`   // idle (default), stop, loop or slate once the queue drains
+	SlateImage         string `json:"slateImage"`         // Image shown by the slate end of queue action
+	StartPaused        bool   `json:"startPaused"`        // Connect and stand by on SlateImage until GoLive, then play the intro and queue
+	KeepAlive          int    `json:"keepAlive"`          // Seconds preprocessing may stall before SlateImage fills the gap, 0 disables
+	IntroFile          string `json:"introFile"`          // Played once when the stream starts, before the first queued entry
+	LiveText           bool   `json:"liveText"`           // Burn in text that SetOverlayText can change while streaming
+	LiveTextPosition   string `json:"liveTextPosition"`   // Overlay position of the live text, default bottom-left
+
+	// Destinations are published to at once alongside Destination, each
+	// with its own credentials. ConnectTimeout only applies to a single
+	// destination, since the tee muxer cannot pass it on.
+	Destinations []StreamDestination `json:"destinations"`
 }
 
 type StreamManager struct {
-	config        Config
-	mu            sync.RWMutex
-	running       bool
-	ctx           context.Context
-	cancel        context.CancelFunc
-	logger        *zap.Logger
-	queue         []entry
-	queueNotify   chan struct{}
-	currentCtx    context.Context
-	currentCancel context.CancelFunc
-	currentEntry  *entry
-	lastError     string
-	lastErrorTime time.Time
-	progressCh    chan progressData
-	fifoPath      string
-	fifo          io.WriteCloser
+	config           Config
+	mu               sync.RWMutex
+	running          bool
+	active           bool // Reserved by TryStart until cleanup finishes, unlike running which Stop clears immediately
+	ctx              context.Context
+	cancel           context.CancelFunc
+	logger           *zap.Logger
+	queue            []entry
+	queueNotify      chan struct{}
+	currentCtx       context.Context
+	currentCancel    context.CancelFunc
+	currentEntry     *entry
+	currentStart     time.Time
+	filtered         bool // Whether the current entry applies filters or overlays on top of the re-encode
+	currentIteration int  // Which time in a row the current entry is playing, from 1
+	currentFirst     bool // Whether the current entry is the first of the session
+	entryBegun       bool // Whether any entry has begun this session
+	held             bool
+	startedAt        time.Time
+	stoppedAt        time.Time     // When the last run finished cleaning up
+	startGrace       time.Duration // How long after stoppedAt new starts are refused
+	teardownDone     chan struct{} // Closed once the current run has cleaned up
+	history          []historyEntry
+	session          *sessionStats // Report of the current or last session
+	played           []entry       // Entries played since the queue last drained, for looping
+	showingSlate     bool
+	buffering        bool // Whether the slate is covering a preprocessing stall
+	standby          bool // Started paused and waiting for GoLive
+	goLive           chan struct{}
+	introPlayed      bool
+	defaultOverlay   OverlaySettings // Applied to entries enqueued without an overlay
+	lastID           int64
+	posters          map[string][]byte
+	liveTextPath     string   // File the live text overlay reads, set while a LiveText session runs
+	streamingArgs    []string // Redacted arguments of the running streaming ffmpeg
+	lastError        string
+	lastErrorTime    time.Time
+	progressCh       chan progressData
+	latest           *progressData // Most recent progress, kept whether or not progressCh is read
+	lastOutTimeUs    int64
+	lastAdvance      time.Time
+	speedFactor      float64
+	clock            clock
+	maxFilters       int
+	fifoPath         string
+	fifo             io.WriteCloser
+
+	// destinationErrors holds why the tee muxer dropped each failed
+	// destination of a multistream, by index
+	destinationErrors map[int]string
+
+	// progressSubs receive every progress update, see SubscribeProgress
+	progressSubs map[chan progressData]struct{}
 }
 
 func New(logger *zap.Logger, fifoPath string) (*StreamManager, error) {
@@ -69,8 +176,11 @@ func New(logger *zap.Logger, fifoPath string) (*StreamManager, error) {
 		logger:      logger,
 		queue:       make([]entry, 0),
 		queueNotify: make(chan struct{}, 1),
+		goLive:      make(chan struct{}, 1),
 		progressCh:  make(chan progressData, 100),
 		fifoPath:    fifoPath,
+		clock:       realClock{},
+		maxFilters:  defaultMaxFilters,
 	}, nil
 }
 
@@ -80,6 +190,17 @@ func (s *StreamManager) cleanup() {
 	defer s.mu.Unlock()
 
 	s.running = false
+	s.active = false
+	s.startedAt = time.Time{}
+	if s.session != nil {
+		s.session.endedAt = s.clock.Now()
+	}
+	s.lastAdvance = time.Time{}
+	s.speedFactor = 0
+	s.latest = nil
+	s.buffering = false
+	s.standby = false
+	s.streamingArgs = nil
 	s.currentEntry = nil
 	if s.currentCancel != nil {
 		s.currentCancel()
@@ -91,42 +212,250 @@ func (s *StreamManager) cleanup() {
 		s.fifo = nil
 	}
 
+	if s.liveTextPath != "" {
+		_ = os.Remove(s.liveTextPath)
+		s.liveTextPath = ""
+	}
+
 	// Reset main context references
+	if s.cancel != nil {
+		s.cancel()
+	}
 	s.ctx = nil
 	s.cancel = nil
 
 	_ = os.Remove(s.fifoPath)
+
+	s.stoppedAt = s.clock.Now()
+	if s.teardownDone != nil {
+		close(s.teardownDone)
+		s.teardownDone = nil
+	}
 }
 
-func (s *StreamManager) Run(ctx context.Context, cfg Config) error {
+// ValidateConfig checks cfg for settings that would make ffmpeg fail
+func ValidateConfig(cfg Config) error {
+	if cfg.ConnectTimeout < 0 {
+		return errors.New("connect timeout must not be negative")
+	}
+	if err := validateEndOfQueue(cfg.EndOfQueueAction, cfg.SlateImage); err != nil {
+		return err
+	}
+	if err := validateKeepAlive(cfg.KeepAlive, cfg.SlateImage); err != nil {
+		return err
+	}
+	if err := validateStartPaused(cfg.StartPaused, cfg.SlateImage); err != nil {
+		return err
+	}
+	if err := validateRealtime(cfg); err != nil {
+		return err
+	}
+	if err := validateDestinations(cfg.Destinations); err != nil {
+		return err
+	}
+	if err := validateTimestampMode(cfg.TimestampMode); err != nil {
+		return err
+	}
+	if cfg.MaxMuxingQueue < 0 {
+		return errors.New("max muxing queue must not be negative")
+	}
+	if cfg.FastStart < 0 {
+		return errors.New("fast start must not be negative")
+	}
+	for _, level := range []struct{ name, value string }{
+		{"log level", cfg.LogLevel},
+		{"preprocess log level", cfg.PreprocessLogLevel},
+		{"stream log level", cfg.StreamLogLevel},
+	} {
+		if err := validateFFmpegLogLevel(level.value); err != nil {
+			return fmt.Errorf("%s: %w", level.name, err)
+		}
+	}
+	if err := validateProfileLevel(cfg.Profile, cfg.Level); err != nil {
+		return err
+	}
+	if err := validateFrameSettings(cfg.BFrames, cfg.RefFrames); err != nil {
+		return err
+	}
+	if err := validatePixelFormat(cfg.PixelFormat); err != nil {
+		return err
+	}
+	if err := validateAudioSampleRate(cfg.AudioSampleRate); err != nil {
+		return err
+	}
+	if err := validateBitrates(cfg.MaxBitrate, cfg.TargetBitrate, cfg.MinBitrate, cfg.BufSize); err != nil {
+		return err
+	}
+	if err := validateOutputFormat(cfg.OutputSize, cfg.OutputFrameRate); err != nil {
+		return err
+	}
+	if err := validateIntroFile(cfg.IntroFile); err != nil {
+		return err
+	}
+	if err := validateRecordPath(cfg.RecordPath); err != nil {
+		return err
+	}
+	return validateEncoderConflicts(cfg.Encoder, cfg.Profile, cfg.PixelFormat)
+}
+
+// validateRealtime rejects features that stream the endless slate when
+// streaming is not paced at realtime, as it would be read as fast as ffmpeg
+// can encode it
+func validateRealtime(cfg Config) error {
+	if !cfg.NoRealtime {
+		return nil
+	}
+	switch {
+	case cfg.EndOfQueueAction == EndOfQueueSlate:
+		return errors.New("end of queue action slate requires realtime streaming")
+	case cfg.KeepAlive > 0:
+		return errors.New("keep-alive requires realtime streaming")
+	case cfg.StartPaused:
+		return errors.New("start paused requires realtime streaming")
+	}
+	return nil
+}
+
+// TryStart reserves the StreamManager for a run with cfg. It reports false
+// if a previous run is still active, including one that is stopping. A
+// successful reservation must be followed by RunStarted.
+func (s *StreamManager) TryStart(cfg Config) bool {
 	s.mu.Lock()
-	if s.running {
-		s.mu.Unlock()
-		return errors.New("already running")
+	defer s.mu.Unlock()
+
+	if _, blocked := s.startBlocked(); blocked || s.running {
+		return false
 	}
 	s.running = true
+	s.active = true
+	// Created with the reservation so Stop can end the run before it begins
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.teardownDone = make(chan struct{})
+	s.startedAt = s.clock.Now()
+	s.held = false
+	s.played = nil
+	s.introPlayed = false
+	s.entryBegun = false
+	s.startSession()
+	s.standby = cfg.StartPaused
+	// Drop a go-live left over from a session stopped while standing by
+	select {
+	case <-s.goLive:
+	default:
+	}
 	s.config = cfg
 	s.lastError = ""
 	s.lastErrorTime = time.Time{}
-	s.mu.Unlock()
+	return true
+}
+
+// startRetryInterval is suggested to callers refused because a stopped run
+// is still cleaning up
+const startRetryInterval = time.Second
+
+// StartBlocked reports whether a new start would be refused because the last
+// run is still tearing down or within the start grace period, and how long
+// to wait before trying again
+func (s *StreamManager) StartBlocked() (time.Duration, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.running {
+		return 0, false
+	}
+	return s.startBlocked()
+}
+
+// startBlocked is StartBlocked for callers holding s.mu
+func (s *StreamManager) startBlocked() (time.Duration, bool) {
+	if s.active {
+		return max(startRetryInterval, s.startGrace), true
+	}
+	if s.stoppedAt.IsZero() {
+		return 0, false
+	}
+	if remaining := s.stoppedAt.Add(s.startGrace).Sub(s.clock.Now()); remaining > 0 {
+		return remaining, true
+	}
+	return 0, false
+}
+
+// TeardownComplete returns a channel that is closed once the current run has
+// stopped and cleaned up its FIFO. It is already closed when nothing runs.
+func (s *StreamManager) TeardownComplete() <-chan struct{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.teardownDone == nil {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return s.teardownDone
+}
+
+// SetStartGracePeriod makes starts wait d after the last run finished
+// cleaning up, so a destination has time to drop the old connection.
+// Negative values are treated as 0.
+func (s *StreamManager) SetStartGracePeriod(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startGrace = max(d, 0)
+}
+
+func (s *StreamManager) Run(ctx context.Context, cfg Config) error {
+	if err := ValidateConfig(cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	if !s.TryStart(cfg) {
+		return errors.New("already running")
+	}
+	return s.RunStarted(ctx)
+}
 
+// RunStarted runs a StreamManager reserved by TryStart until ctx is
+// cancelled, Stop is called or streaming fails
+func (s *StreamManager) RunStarted(ctx context.Context) error {
 	// Ensure cleanup runs on any exit
 	defer s.cleanup()
 
+	s.mu.RLock()
+	runCtx, cancel := s.ctx, s.cancel
+	s.mu.RUnlock()
+	defer cancel()
+	// The caller's ctx ends the run the same way Stop does
+	stopOnCaller := context.AfterFunc(ctx, cancel)
+	defer stopOnCaller()
+
+	if runCtx.Err() != nil {
+		s.logger.Info("StreamManager stopped before it started")
+		return nil
+	}
+
 	_ = os.Remove(s.fifoPath)
 	if err := syscall.Mkfifo(s.fifoPath, 0o0644); err != nil {
 		return fmt.Errorf("failed to create fifo: %w", err)
 	}
 
+	if err := s.createLiveTextFile(); err != nil {
+		return err
+	}
+
 	s.logger.Info("StreamManager started")
 
-	eg, ctx := errgroup.WithContext(ctx)
-	s.ctx, s.cancel = context.WithCancel(ctx)
+	eg, egCtx := errgroup.WithContext(runCtx)
+	// Either goroutine failing ends the whole run, including the playing entry
+	stopOnFailure := context.AfterFunc(egCtx, cancel)
+	defer stopOnFailure()
 
 	eg.Go(func() error {
-		time.Sleep(5 * time.Second)
+		select {
+		case <-s.clock.After(5 * time.Second):
+		case <-runCtx.Done():
+			// Stopped before streaming began
+			return nil
+		}
 		s.logger.Info("Streaming FIFO reader", zap.String("destination", s.config.Destination))
-		if err := s.readFromFIFO(s.ctx, s.fifoPath); err != nil {
+		if err := s.readFromFIFO(runCtx, s.fifoPath); err != nil {
 			if errors.Is(err, context.Canceled) {
 				s.logger.Debug("FIFO reader cancelled")
 				return nil
@@ -139,81 +468,293 @@ func (s *StreamManager) Run(ctx context.Context, cfg Config) error {
 
 	eg.Go(func() error {
 		var err error
-		s.fifo, err = os.OpenFile(s.fifoPath, os.O_WRONLY, os.ModeNamedPipe)
+		s.fifo, err = openFIFOWriter(runCtx, s.fifoPath)
 		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				s.logger.Debug("FIFO writer cancelled before the reader opened it")
+				return nil
+			}
 			return err
 		}
 
-		for {
+		s.standBy(runCtx)
+		s.playIntro(runCtx)
+
+		return s.processQueue(runCtx, s.playEntry)
+	})
+
+	err := eg.Wait()
+
+	s.logger.Info("StreamManager stopped", zap.Error(err))
+	return err
+}
+
+// processQueue plays queued entries with play until ctx is done or the end
+// of queue action stops the stream
+func (s *StreamManager) processQueue(ctx context.Context, play func(context.Context, entry) error) error {
+	for {
+		if ctx.Err() != nil {
+			s.logger.Debug("Queue processor context cancelled")
+			return nil
+		}
+
+		// Check the queue itself rather than count notifications, which
+		// coalesce while an entry plays
+		entry, ok := s.nextEntry()
+		if !ok {
 			select {
-			case <-s.ctx.Done():
-				s.logger.Debug("Queue processor context cancelled")
-				return nil
+			case <-ctx.Done():
 			case <-s.queueNotify:
-				s.mu.Lock()
-				if len(s.queue) == 0 {
-					s.mu.Unlock()
-					continue
-				}
-				entry := s.queue[0]
-				s.queue = s.queue[1:]
-				s.currentEntry = &entry
-				s.currentCtx, s.currentCancel = context.WithCancel(s.ctx)
-				s.mu.Unlock()
-
-				s.logger.Info("Processing file",
+			}
+			continue
+		}
+		err := s.playRepeats(ctx, entry, play)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				s.logger.Info("Processing of file was cancelled",
 					zap.String("file", entry.File),
-					zap.String("id", entry.ID),
-					zap.String("startTimestamp", entry.StartTimestamp),
-					zap.String("subtitleFile", entry.SubtitleFile))
-				if err := s.writeToFIFO(s.currentCtx, entry.File, entry.Overlay, entry.StartTimestamp, entry.SubtitleFile); err != nil {
-					if errors.Is(err, context.Canceled) {
-						s.logger.Info("Processing of file was cancelled",
-							zap.String("file", entry.File),
-							zap.String("id", entry.ID))
-					} else {
-						s.logger.Error("Failed to write file to fifo",
-							zap.String("file", entry.File),
-							zap.Error(err))
-						s.setError(fmt.Sprintf("FFmpeg processing failed for %s: %v", entry.File, err))
-						return fmt.Errorf("ffmpeg failed: %w", err)
-					}
-					s.mu.Lock()
-					s.currentEntry = nil
-					s.currentCancel = nil
-					s.mu.Unlock()
-					continue
+					zap.String("id", entry.ID))
+				if s.endOfQueue(ctx) {
+					return s.closeFIFO()
 				}
-
-				s.logger.Info("Successfully wrote file to fifo", zap.String("file", entry.File))
-				s.mu.Lock()
-				s.currentEntry = nil
-				s.currentCancel = nil
-				s.mu.Unlock()
+				continue
 			}
+			s.logger.Error("Failed to write file to fifo",
+				zap.String("file", entry.File),
+				zap.Error(err))
+			s.setError(fmt.Sprintf("FFmpeg processing failed for %s: %v", entry.File, err))
+			return fmt.Errorf("ffmpeg failed: %w", err)
 		}
-	})
 
-	err := eg.Wait()
+		s.logger.Info("Successfully wrote file to fifo", zap.String("file", entry.File))
+		if s.endOfQueue(ctx) {
+			return s.closeFIFO()
+		}
+	}
+}
 
-	s.logger.Info("StreamManager stopped", zap.Error(err))
-	return err
+// playRepeats plays e as many times in a row as it repeats. Skip only ends
+// the current iteration, while Abort, Stop and failures end the entry.
+func (s *StreamManager) playRepeats(ctx context.Context, e entry, play func(context.Context, entry) error) error {
+	repeat := max(e.Repeat, 1)
+	for iteration := 1; ; iteration++ {
+		entryCtx := s.beginEntry(e, iteration)
+
+		s.logger.Info("Processing file",
+			zap.String("file", e.File),
+			zap.String("id", e.ID),
+			zap.Int("iteration", iteration),
+			zap.Int("repeat", repeat),
+			zap.String("startTimestamp", e.StartTimestamp),
+			zap.String("subtitleFile", e.SubtitleFile))
+		err := play(entryCtx, e)
+		s.finishEntry(e, err)
+
+		if iteration >= repeat || ctx.Err() != nil || s.isHeld() {
+			return err
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+}
+
+// isHeld reports whether Abort is holding the queue
+func (s *StreamManager) isHeld() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.held
+}
+
+// nextEntry removes and returns the entry at the front of the queue. It
+// reports false when the queue is empty or held.
+func (s *StreamManager) nextEntry() (entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 || s.held {
+		return entry{}, false
+	}
+	e := s.queue[0]
+	s.queue = s.queue[1:]
+	return e, true
+}
+
+// beginEntry makes iteration of e the current entry and returns the context
+// that Skip and Abort cancel to end it
+func (s *StreamManager) beginEntry(e entry, iteration int) context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.State, e.StateSince = statePreprocessing, s.clock.Now()
+	s.currentEntry = &e
+	s.currentIteration = iteration
+	s.currentStart = e.StateSince
+	s.filtered = false
+	s.currentFirst = !s.entryBegun
+	s.entryBegun = true
+	s.currentCtx, s.currentCancel = context.WithCancel(s.ctx)
+	return s.currentCtx
+}
+
+// setEntryState moves the playing entry with id to state. It is a no-op once
+// the entry has finished.
+func (s *StreamManager) setEntryState(id, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.currentEntry != nil && s.currentEntry.ID == id {
+		s.currentEntry.State = state
+		s.currentEntry.StateSince = s.clock.Now()
+	}
+}
+
+// playEntry writes the entry to the FIFO, retrying once without overlays and
+// subtitles when they are enabled and the fallback is configured
+func (s *StreamManager) playEntry(ctx context.Context, e entry) error {
+	err := s.writeToFIFO(ctx, e)
+	if err == nil || errors.Is(err, context.Canceled) || !s.config.OverlayFallback || !hasOverlays(e) {
+		return err
+	}
+
+	s.logger.Warn("Entry failed with overlays enabled, retrying with overlays dropped",
+		zap.String("file", e.File),
+		zap.String("id", e.ID),
+		zap.Error(err))
+	return s.writeToFIFO(ctx, withoutOverlays(e))
+}
+
+// hasOverlays reports whether the entry burns anything into the video
+func hasOverlays(e entry) bool {
+	return e.Overlay.ShowFilename || e.Overlay.WatermarkFile != "" || e.SubtitleFile != ""
+}
+
+// withoutOverlays returns a copy of the entry with overlays and subtitles disabled
+func withoutOverlays(e entry) entry {
+	e.Overlay.ShowFilename = false
+	e.Overlay.WatermarkFile = ""
+	e.SubtitleFile = ""
+	e.SubtitleEncoding = ""
+	return e
+}
+
+// finishEntry clears the current entry and records how it ended in the history
+func (s *StreamManager) finishEntry(e entry, err error) {
+	h := historyEntry{
+		ID:      e.ID,
+		File:    e.File,
+		EndedAt: s.clock.Now(),
+		Outcome: outcomeCompleted,
+	}
+
+	replay := false
+	switch {
+	case err == nil:
+		replay = true
+	case errors.Is(err, context.Canceled) && s.ctx.Err() != nil:
+		h.Outcome = outcomeStopped
+	case errors.Is(err, context.Canceled):
+		h.Outcome = outcomeSkipped
+		replay = true
+	default:
+		h.Outcome = outcomeFailed
+		h.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h.StartedAt = s.currentStart
+	s.currentEntry = nil
+	if s.currentCancel != nil {
+		s.currentCancel()
+		s.currentCancel = nil
+	}
+	s.recordHistory(h)
+	if s.session != nil {
+		s.session.entries = append(s.session.entries, h)
+	}
+	// Looping requeues the entry with its repeat, so record it only once
+	if replay && s.currentIteration <= 1 {
+		s.played = append(s.played, e)
+	}
+}
+
+// closeFIFO closes the write end of the FIFO so the streaming ffmpeg reaches
+// the end of its input and exits cleanly
+func (s *StreamManager) closeFIFO() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fifo == nil {
+		return nil
+	}
+	err := s.fifo.Close()
+	s.fifo = nil
+	if err != nil {
+		return fmt.Errorf("failed to close fifo: %w", err)
+	}
+	return nil
+}
+
+// openFIFOWriter opens the write end of the FIFO at path, which blocks until
+// the streaming ffmpeg opens the read end. If ctx is done first, because
+// that ffmpeg failed to start or the run was stopped, it opens the read end
+// itself to release the blocked open and returns ctx's error.
+func openFIFOWriter(ctx context.Context, path string) (*os.File, error) {
+	type result struct {
+		f   *os.File
+		err error
+	}
+	opened := make(chan result, 1)
+	go func() {
+		f, err := os.OpenFile(path, os.O_WRONLY, os.ModeNamedPipe)
+		opened <- result{f, err}
+	}()
+
+	select {
+	case r := <-opened:
+		return r.f, r.err
+	case <-ctx.Done():
+	}
+
+	reader, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, os.ModeNamedPipe)
+	if err != nil {
+		return nil, fmt.Errorf("failed to release fifo writer: %w", err)
+	}
+	defer reader.Close()
+	if r := <-opened; r.f != nil {
+		_ = r.f.Close()
+	}
+	return nil, ctx.Err()
 }
 
-func (s *StreamManager) Enqueue(file string, overlay OverlaySettings, startTimestamp string, subtitleFile string) string {
+// newEntryID returns a unique, increasing queue entry id. The caller must
+// hold s.mu.
+func (s *StreamManager) newEntryID() string {
+	s.lastID = max(s.clock.Now().UnixNano(), s.lastID+1)
+	return strconv.FormatInt(s.lastID, 10)
+}
+
+func (s *StreamManager) Enqueue(file string, opts EntryOptions) string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	id := fmt.Sprintf("%d", time.Now().UnixNano())
-	entry := entry{ID: id, File: file, Overlay: overlay, StartTimestamp: startTimestamp, SubtitleFile: subtitleFile}
+	id := s.newEntryID()
+	entry := entry{ID: id, File: file, State: stateQueued, StateSince: s.clock.Now(), EntryOptions: opts}
 	s.queue = append(s.queue, entry)
 
+	s.notifyQueue()
+
+	return id
+}
+
+// notifyQueue wakes the queue processor without blocking
+func (s *StreamManager) notifyQueue() {
 	select {
 	case s.queueNotify <- struct{}{}:
 	default:
 	}
-
-	return id
 }
 
 func (s *StreamManager) Dequeue(id string) bool {
@@ -229,6 +770,150 @@ func (s *StreamManager) Dequeue(id string) bool {
 	return false
 }
 
+// ClearQueue removes every queued entry and returns how many there were. The
+// entry playing has already left the queue and carries on.
+func (s *StreamManager) ClearQueue() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := len(s.queue)
+	s.queue = nil
+	return removed
+}
+
+// MoveUp swaps the queued entry with its predecessor. It reports whether the
+// entry was found; moving the first entry up is a no-op.
+func (s *StreamManager) MoveUp(id string) bool {
+	return s.move(id, -1)
+}
+
+// MoveDown swaps the queued entry with its successor. It reports whether the
+// entry was found; moving the last entry down is a no-op.
+func (s *StreamManager) MoveDown(id string) bool {
+	return s.move(id, 1)
+}
+
+func (s *StreamManager) move(id string, delta int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, entry := range s.queue {
+		if entry.ID == id {
+			j := i + delta
+			if j >= 0 && j < len(s.queue) {
+				s.queue[i], s.queue[j] = s.queue[j], s.queue[i]
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Reorder moves the queued entry to position, counted from the front of the
+// queue, shifting the entries in between. It reports whether the entry was
+// found, and an error when position is outside the queue.
+func (s *StreamManager) Reorder(id string, position int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.queue, func(e entry) bool { return e.ID == id })
+	if i < 0 {
+		return false, nil
+	}
+	if position < 0 || position >= len(s.queue) {
+		return true, fmt.Errorf("position %d is out of range, the queue has %d entries", position, len(s.queue))
+	}
+
+	e := s.queue[i]
+	s.queue = slices.Insert(slices.Delete(s.queue, i, i+1), position, e)
+	return true, nil
+}
+
+// OverlayUpdate changes the overlay fields that are set, leaving the rest
+type OverlayUpdate struct {
+	ShowFilename      *bool   `json:"showFilename"`
+	Position          *string `json:"position"`
+	FontSize          *int    `json:"fontSize"`
+	WatermarkFile     *string `json:"watermarkFile"`
+	WatermarkPosition *string `json:"watermarkPosition"`
+}
+
+// EntryUpdate changes the options of a queued entry that are set. An empty
+// StartTimestamp or SubtitleFile clears it.
+type EntryUpdate struct {
+	Overlay        *OverlayUpdate `json:"overlay"`
+	StartTimestamp *string        `json:"startTimestamp"`
+	SubtitleFile   *string        `json:"subtitleFile"`
+}
+
+// Update applies the changes to the queued entry with id. It reports whether
+// the entry was found, which the entry playing no longer is, and an error
+// when a change is invalid, in which case none are applied.
+func (s *StreamManager) Update(ctx context.Context, id string, update EntryUpdate) (bool, error) {
+	s.mu.RLock()
+	i := slices.IndexFunc(s.queue, func(e entry) bool { return e.ID == id })
+	if i < 0 {
+		s.mu.RUnlock()
+		return false, nil
+	}
+	e := s.queue[i]
+	s.mu.RUnlock()
+
+	if update.Overlay != nil {
+		if update.Overlay.ShowFilename != nil {
+			e.Overlay.ShowFilename = *update.Overlay.ShowFilename
+		}
+		if update.Overlay.Position != nil {
+			e.Overlay.Position = *update.Overlay.Position
+		}
+		if update.Overlay.FontSize != nil {
+			e.Overlay.FontSize = *update.Overlay.FontSize
+		}
+		if update.Overlay.WatermarkFile != nil {
+			e.Overlay.WatermarkFile = *update.Overlay.WatermarkFile
+		}
+		if update.Overlay.WatermarkPosition != nil {
+			e.Overlay.WatermarkPosition = *update.Overlay.WatermarkPosition
+		}
+		if err := validateOverlay(e.Overlay); err != nil {
+			return true, err
+		}
+	}
+	if update.StartTimestamp != nil {
+		if err := s.validateStartTimestamp(ctx, e.File, *update.StartTimestamp); err != nil {
+			return true, fmt.Errorf("timestamp validation failed: %w", err)
+		}
+		// The entry keeps its end, which must still come after the new start
+		if err := s.validateEndTimestamp(ctx, e.File, *update.StartTimestamp, e.EndTimestamp); err != nil {
+			return true, fmt.Errorf("end timestamp validation failed: %w", err)
+		}
+		e.StartTimestamp = *update.StartTimestamp
+	}
+	if update.SubtitleFile != nil {
+		if err := s.validateSubtitleFile(*update.SubtitleFile); err != nil {
+			return true, fmt.Errorf("subtitle validation failed: %w", err)
+		}
+		if *update.SubtitleFile != e.SubtitleFile {
+			// An explicit encoding was for the old file
+			e.SubtitleEncoding = ""
+		}
+		e.SubtitleFile = *update.SubtitleFile
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The entry may have started playing or been removed while validating
+	i = slices.IndexFunc(s.queue, func(e entry) bool { return e.ID == id })
+	if i < 0 {
+		return false, nil
+	}
+	s.queue[i].EntryOptions = e.EntryOptions
+	// The poster is taken at the start timestamp
+	delete(s.posters, id)
+	return true, nil
+}
+
 func (s *StreamManager) Queue() []entry {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -246,13 +931,57 @@ func (s *StreamManager) Status() map[string]any {
 		"running":           s.running,
 		"activelyStreaming": s.currentEntry != nil,
 		"queueLength":       len(s.queue),
+		"held":              s.held,
+		"waitingForContent": s.running && s.currentEntry == nil && len(s.queue) == 0,
+		"endOfQueueAction":  s.endOfQueueAction(),
+		"showingSlate":      s.showingSlate,
+		"buffering":         s.buffering,
+		"standby":           s.standby,
+	}
+
+	if s.config.IntroFile != "" {
+		status["introPlayed"] = s.introPlayed
+	}
+
+	if s.running && len(s.config.Destinations) > 0 {
+		status["destinations"] = s.destinationStatus()
+	}
+
+	if s.running && s.config.RecordPath != "" {
+		status["recording"] = s.recordingStatus()
+	}
+
+	if s.running && !s.startedAt.IsZero() {
+		status["since"] = s.startedAt.Unix()
 	}
 
+	if s.running && !s.lastAdvance.IsZero() {
+		stalledSeconds, stalled := s.stallStatus()
+		status["stalledSeconds"] = stalledSeconds
+		status["stalled"] = stalled
+	}
+
+	if s.running && s.speedFactor > 0 {
+		status["speedFactor"] = s.speedFactor
+		status["keepingUp"] = s.speedFactor >= minKeepingUpSpeed
+	}
+
+	// Preprocessing always re-encodes, so filtered is what sets one entry's
+	// CPU cost apart from another's. The output stage always stream copies.
 	if s.currentEntry != nil {
-		status["playing"] = map[string]string{
-			"id":   s.currentEntry.ID,
-			"file": s.currentEntry.File,
+		playing := map[string]any{
+			"id":         s.currentEntry.ID,
+			"file":       s.currentEntry.File,
+			"startedAt":  s.currentStart.Unix(),
+			"filtered":   s.filtered,
+			"state":      s.currentEntry.State,
+			"stateSince": s.currentEntry.StateSince.Unix(),
 		}
+		if s.currentEntry.Repeat > 1 {
+			playing["iteration"] = s.currentIteration
+			playing["repeat"] = s.currentEntry.Repeat
+		}
+		status["playing"] = playing
 	}
 
 	if s.lastError != "" {
@@ -265,11 +994,39 @@ func (s *StreamManager) Status() map[string]any {
 	return status
 }
 
+// QueueCount is the subset of Status cheap enough for frequent polling
+type QueueCount struct {
+	Running           bool   `json:"running"`
+	ActivelyStreaming bool   `json:"activelyStreaming"`
+	QueueLength       int    `json:"queueLength"`
+	CurrentID         string `json:"currentId,omitempty"`
+}
+
+// QueueCount reports whether the stream runs and how much is left without
+// copying the queue or building the full status
+func (s *StreamManager) QueueCount() QueueCount {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := QueueCount{
+		Running:           s.running,
+		ActivelyStreaming: s.currentEntry != nil,
+		QueueLength:       len(s.queue),
+	}
+	if s.currentEntry != nil {
+		count.CurrentID = s.currentEntry.ID
+	}
+	return count
+}
+
 func (s *StreamManager) setError(errMsg string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.lastError = errMsg
-	s.lastErrorTime = time.Now()
+	s.lastErrorTime = s.clock.Now()
+	if s.session != nil {
+		s.session.errors = append(s.session.errors, errMsg)
+	}
 }
 
 func (s *StreamManager) Skip() bool {
@@ -283,6 +1040,33 @@ func (s *StreamManager) Skip() bool {
 	return false
 }
 
+// Abort cancels the current entry and holds the queue so the next entry is
+// not picked up until Resume is called.
+func (s *StreamManager) Abort() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.currentCancel != nil {
+		s.held = true
+		s.currentCancel()
+		return true
+	}
+	return false
+}
+
+// Resume releases a queue held by Abort. It reports whether the queue was held.
+func (s *StreamManager) Resume() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.held {
+		return false
+	}
+	s.held = false
+	s.notifyQueue()
+	return true
+}
+
 func (s *StreamManager) Stop() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -295,37 +1079,202 @@ func (s *StreamManager) Stop() bool {
 	return false
 }
 
-func (s *StreamManager) writeToFIFO(ctx context.Context, source string, overlay OverlaySettings, startTimestamp string, subtitleFile string) error {
+// fastStartFor returns the fast start seconds for e, which only apply to the
+// first entry of the session. The caller must hold s.mu.
+func (s *StreamManager) fastStartFor(e entry) int {
+	if s.currentFirst && s.currentEntry != nil && s.currentEntry.ID == e.ID {
+		return s.config.FastStart
+	}
+	return 0
+}
+
+// preprocessingArgs returns the ffmpeg settings used to preprocess e
+func (s *StreamManager) preprocessingArgs(e entry, probeInfo fileProbeInfo) ffmpegArgs {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return ffmpegArgs{
+		source:           e.File,
+		inputFormat:      e.InputFormat,
+		overlay:          e.Overlay,
+		startTimestamp:   e.StartTimestamp,
+		endTimestamp:     e.EndTimestamp,
+		subtitleFile:     e.SubtitleFile,
+		imageFile:        e.ImageFile,
+		mute:             e.Mute,
+		fadeIn:           e.FadeIn,
+		fadeOut:          e.FadeOut,
+		volume:           e.Volume,
+		logLevel:         processLogLevel(s.config.PreprocessLogLevel, s.config.LogLevel),
+		encoder:          s.config.Encoder,
+		preset:           s.config.Preset,
+		keyframeInterval: s.config.KeyframeInterval,
+		bFrames:          s.config.BFrames,
+		refFrames:        s.config.RefFrames,
+		noSceneCut:       s.config.NoSceneCut,
+		fastStart:        s.fastStartFor(e),
+		autoRotate:       s.config.AutoRotate,
+		profile:          s.config.Profile,
+		level:            s.config.Level,
+		pixelFormat:      s.config.PixelFormat,
+		maxBitrate:       s.config.MaxBitrate,
+		targetBitrate:    s.config.TargetBitrate,
+		minBitrate:       s.config.MinBitrate,
+		bufSize:          s.config.BufSize,
+		sampleRate:       s.config.AudioSampleRate,
+		normalizeAudio:   s.config.NormalizeAudio,
+		silentAudio:      s.config.SilentAudio,
+		outputSize:       s.config.OutputSize,
+		frameRate:        s.config.OutputFrameRate,
+		legacyFPSMode:    !CurrentFFmpegVersion().Supported(),
+		liveTextFile:     s.liveTextPath,
+		liveTextPosition: s.config.LiveTextPosition,
+		streamTitle:      s.config.StreamTitle,
+		metadata:         e.Metadata,
+		probeInfo:        probeInfo,
+	}
+}
+
+// resolvedPreprocessingArgs returns the settings e will be preprocessed with,
+// including those read from its subtitle file
+func (s *StreamManager) resolvedPreprocessingArgs(e entry, probeInfo fileProbeInfo) ffmpegArgs {
+	cfg := s.preprocessingArgs(e, probeInfo)
+	cfg.subtitleSize = s.assSubtitleSize(e.SubtitleFile, probeInfo)
+	cfg.subtitleCharenc = s.subtitleCharenc(e)
+	return cfg
+}
+
+// StreamingCommand returns the arguments of the running streaming ffmpeg,
+// with credentials redacted. It reports false when no stream is running.
+func (s *StreamManager) StreamingCommand() ([]string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.streamingArgs == nil {
+		return nil, false
+	}
+	return slices.Clone(s.streamingArgs), true
+}
+
+// PreviewNext returns the entry at the front of the queue and the ffmpeg
+// arguments that will preprocess it, without changing the queue. It reports
+// false when the queue is empty.
+func (s *StreamManager) PreviewNext(ctx context.Context) (entry, []string, bool) {
+	s.mu.RLock()
+	if len(s.queue) == 0 {
+		s.mu.RUnlock()
+		return entry{}, nil, false
+	}
+	next := s.queue[0]
+	password, streamKey := s.config.Password, s.config.StreamKey
+	s.mu.RUnlock()
+
+	args := buildPreprocessingArgs(s.resolvedPreprocessingArgs(next, probeFile(ctx, s.logger, next.File)))
+	for i, arg := range args {
+		args[i] = redactSecrets(arg, password, streamKey)
+	}
+	return next, args, true
+}
+
+func (s *StreamManager) writeToFIFO(ctx context.Context, e entry) error {
 	// Validate start timestamp if provided
-	if err := s.validateStartTimestamp(ctx, source, startTimestamp); err != nil {
+	if err := s.validateStartTimestamp(ctx, e.File, e.StartTimestamp); err != nil {
 		return fmt.Errorf("timestamp validation failed: %w", err)
 	}
 
+	if err := s.validateEndTimestamp(ctx, e.File, e.StartTimestamp, e.EndTimestamp); err != nil {
+		return fmt.Errorf("end timestamp validation failed: %w", err)
+	}
+
 	// Validate subtitle file if provided
-	if err := s.validateSubtitleFile(subtitleFile); err != nil {
+	if err := s.validateSubtitleFile(e.SubtitleFile); err != nil {
+		return fmt.Errorf("subtitle validation failed: %w", err)
+	}
+
+	if err := validateSubtitleEncoding(e.SubtitleFile, e.SubtitleEncoding); err != nil {
 		return fmt.Errorf("subtitle validation failed: %w", err)
 	}
 
+	if err := validateImageFile(e.ImageFile); err != nil {
+		return fmt.Errorf("image validation failed: %w", err)
+	}
+
+	if err := validateWatermark(e.Overlay); err != nil {
+		return fmt.Errorf("overlay validation failed: %w", err)
+	}
+
+	if err := validateInputFormat(ctx, e.InputFormat); err != nil {
+		return fmt.Errorf("input format validation failed: %w", err)
+	}
+
+	if err := validateMetadata(e.Metadata); err != nil {
+		return fmt.Errorf("metadata validation failed: %w", err)
+	}
+
 	// Probe the source file to get audio information
-	probeInfo := probeFile(ctx, s.logger, source)
+	probeInfo := probeFile(ctx, s.logger, e.File)
 
-	cfg := ffmpegArgs{
-		source:           source,
-		overlay:          overlay,
-		startTimestamp:   startTimestamp,
-		subtitleFile:     subtitleFile,
-		logLevel:         s.config.LogLevel,
-		encoder:          s.config.Encoder,
-		preset:           s.config.Preset,
-		keyframeInterval: s.config.KeyframeInterval,
-		maxBitrate:       s.config.MaxBitrate,
-		probeInfo:        probeInfo,
+	if err := validateFades(e.FadeIn, e.FadeOut, e.StartTimestamp, e.EndTimestamp, probeInfo.duration); err != nil {
+		return fmt.Errorf("fade validation failed: %w", err)
+	}
+
+	if err := validateEntryConflicts(e.EntryOptions, probeInfo); err != nil {
+		return fmt.Errorf("option validation failed: %w", err)
+	}
+
+	cfg := s.resolvedPreprocessingArgs(e, probeInfo)
+
+	// A normalized entry is scaled to the output size, whatever its own
+	if cfg.outputSize == "" {
+		if err := validateOutputDimensions(probeInfo.width, probeInfo.height); err != nil {
+			return fmt.Errorf("resolution validation failed: %w", err)
+		}
+	}
+	if cfg.subtitleCharenc != "" {
+		s.logger.Info("Converting subtitles to UTF-8",
+			zap.String("subtitleFile", e.SubtitleFile),
+			zap.String("encoding", cfg.subtitleCharenc))
+	}
+
+	if err := s.validateFilterCount(cfg); err != nil {
+		return fmt.Errorf("filter validation failed: %w", err)
 	}
+	s.logger.Debug("Composed filtergraph",
+		zap.String("id", e.ID),
+		zap.String("video", buildVideoFilter(cfg)),
+		zap.String("audio", buildAudioFilter(cfg)))
 
 	args := buildFFmpegArgs(cfg)
 
+	s.mu.Lock()
+	s.filtered = filterCount(cfg) > 0
+	s.mu.Unlock()
+
+	// Preprocessing always re-encodes, so every run holds a shared slot
+	release, err := acquireEncodeSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	s.mu.RLock()
+	keepAlive := time.Duration(s.config.KeepAlive) * time.Second
+	fifo := s.fifo
+	s.mu.RUnlock()
+
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-	cmd.Stdout = s.fifo
+	cmd.Stdout = fifo
+
+	// With keep-alive the output goes through a copier that can cover stalls
+	// with the slate instead of straight into the FIFO
+	var (
+		pipeReader *io.PipeReader
+		pipeWriter *io.PipeWriter
+	)
+	if keepAlive > 0 {
+		pipeReader, pipeWriter = io.Pipe()
+		cmd.Stdout = pipeWriter
+	}
 
 	// Capture stderr for error reporting while also writing to file for preprocessing logs
 	var stderrBuf strings.Builder
@@ -354,8 +1303,13 @@ func (s *StreamManager) writeToFIFO(ctx context.Context, source string, overlay
 	}
 
 	s.logger.Info("Running ffmpeg write command", zap.Stringer("cmd", cmd), zap.String("log", logFile.Name()))
+	s.setEntryState(e.ID, stateStreaming)
 
-	err = cmd.Run()
+	if keepAlive > 0 {
+		err = s.runWithKeepAlive(ctx, cmd, fifo, pipeReader, pipeWriter, keepAlive)
+	} else {
+		err = cmd.Run()
+	}
 	if err != nil {
 		if ctx.Err() != nil {
 			return ctx.Err()
@@ -370,27 +1324,40 @@ func (s *StreamManager) writeToFIFO(ctx context.Context, source string, overlay
 }
 
 func (s *StreamManager) readFromFIFO(ctx context.Context, fifo string) error {
-	dest := s.config.Destination
-	if s.config.Username != "" && s.config.Password != "" {
-		if strings.HasPrefix(dest, "rtmp://") {
-			dest = strings.Replace(dest, "rtmp://", fmt.Sprintf("rtmp://%s:%s@", s.config.Username, s.config.Password), 1)
-		}
-	}
-
 	cfg := ffmpegArgs{
-		fifoPath:    fifo,
-		destination: dest,
-		username:    s.config.Username,
-		password:    s.config.Password,
-		logLevel:    s.config.LogLevel,
+		fifoPath:           fifo,
+		destination:        s.config.Destination,
+		destinations:       s.config.Destinations,
+		recordPath:         s.config.RecordPath,
+		streamTitle:        s.config.StreamTitle,
+		streamKey:          s.config.StreamKey,
+		username:           s.config.Username,
+		connectTimeout:     s.config.ConnectTimeout,
+		noRealtime:         s.config.NoRealtime,
+		timestampMode:      s.config.TimestampMode,
+		maxMuxingQueueSize: s.config.MaxMuxingQueue,
+		password:           s.config.Password,
+		logLevel:           processLogLevel(s.config.StreamLogLevel, s.config.LogLevel),
 	}
 
 	args := buildFFmpegArgs(cfg)
 
+	secrets := configSecrets(s.config)
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = redactSecrets(arg, secrets...)
+	}
+	s.mu.Lock()
+	s.streamingArgs = redacted
+	s.destinationErrors = nil
+	s.mu.Unlock()
+
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 
 	// Capture stderr for error reporting while also writing to file and stdout for streaming logs
 	var stderrBuf strings.Builder
+	// Track destinations the tee muxer drops while the others carry on
+	failures := &teeFailureWriter{failed: s.markDestinationFailed}
 
 	// Create temporary log file for ffmpeg streaming output
 	logFile, err := os.CreateTemp("", "streammanager-ffmpeg-read-*.log")
@@ -401,7 +1368,7 @@ func (s *StreamManager) readFromFIFO(ctx context.Context, fifo string) error {
 			prefix: "[STREAMING] ",
 			writer: os.Stdout,
 		}
-		cmd.Stderr = io.MultiWriter(&stderrBuf, streamingWriter)
+		cmd.Stderr = io.MultiWriter(&stderrBuf, streamingWriter, failures)
 	} else {
 		defer func() {
 			logFile.Close()
@@ -418,7 +1385,7 @@ func (s *StreamManager) readFromFIFO(ctx context.Context, fifo string) error {
 			prefix: "[STREAMING] ",
 			writer: os.Stdout,
 		}
-		cmd.Stderr = io.MultiWriter(&stderrBuf, fileWriter, stdoutWriter)
+		cmd.Stderr = io.MultiWriter(&stderrBuf, fileWriter, stdoutWriter, failures)
 	}
 
 	stdout, err := cmd.StdoutPipe()
@@ -426,14 +1393,22 @@ func (s *StreamManager) readFromFIFO(ctx context.Context, fifo string) error {
 		return fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
-	s.logger.Info("Running ffmpeg read command", zap.Stringer("cmd", cmd), zap.String("log", logFile.Name()))
+	s.logger.Info("Running ffmpeg read command",
+		zap.String("cmd", redactSecrets(cmd.String(), secrets...)),
+		zap.String("log", logFile.Name()))
 
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start ffmpeg: %w", err)
 	}
 
-	// Start a goroutine to parse progress data
-	go parseProgress(ctx, stdout, s.progressCh)
+	s.mu.Lock()
+	s.resetStallTracking()
+	s.mu.Unlock()
+
+	// Start goroutines to parse progress data and track stalls
+	rawProgress := make(chan progressData, 100)
+	go parseProgress(ctx, stdout, rawProgress)
+	go s.trackProgress(ctx, rawProgress, s.progressCh)
 
 	err = cmd.Wait()
 	if err != nil {
@@ -441,6 +1416,13 @@ func (s *StreamManager) readFromFIFO(ctx context.Context, fifo string) error {
 			return ctx.Err()
 		}
 		stderrOutput := strings.TrimSpace(stderrBuf.String())
+		if s.config.ConnectTimeout > 0 && strings.Contains(strings.ToLower(stderrOutput), "timed out") {
+			return fmt.Errorf("destination did not respond within %ds: %w", s.config.ConnectTimeout, err)
+		}
+		if strings.Contains(stderrOutput, muxingQueueOverflow) {
+			return fmt.Errorf("output muxing queue overflowed at %s packets, raise maxMuxingQueue or set outputSize and outputFrameRate so entries match: %w",
+				maxMuxingQueueSize(s.config.MaxMuxingQueue), err)
+		}
 		if stderrOutput != "" {
 			return fmt.Errorf("ffmpeg failed: %w\nFFmpeg stderr: %s", err, stderrOutput)
 		}
@@ -449,19 +1431,53 @@ func (s *StreamManager) readFromFIFO(ctx context.Context, fifo string) error {
 	return nil
 }
 
+// pingTimeout bounds how long a destination ping may take including connection setup
+const pingTimeout = 15 * time.Second
+
+// PingDestination publishes a two second test pattern to the destination,
+// with the stream key and credentials joined the way streaming joins them, to
+// check that it is reachable and accepts them. Secrets are redacted from the
+// ffmpeg output in the error.
+func PingDestination(ctx context.Context, destination, streamKey, username, password string) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	joined := joinStreamKey(destination, streamKey)
+	dest := buildDestination(joined, username, password)
+	cmd := exec.CommandContext(ctx, "ffmpeg", buildTestPatternArgs(dest, 2)...)
+
+	var stderrBuf strings.Builder
+	cmd.Stderr = &stderrBuf
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out publishing to destination: %w", ctx.Err())
+		}
+		stderrOutput := strings.TrimSpace(stderrBuf.String())
+		if stderrOutput != "" {
+			stderrOutput = redactSecrets(stderrOutput, password, streamKey, urlStreamKey(joined))
+			return fmt.Errorf("ffmpeg failed: %w\nFFmpeg stderr: %s", err, stderrOutput)
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return nil
+}
+
 // GetProgressChan returns a channel that receives progress updates
 func (s *StreamManager) GetProgressChan() <-chan progressData {
 	return s.progressCh
 }
 
-// GetLatestProgress returns the latest progress data (non-blocking)
+// GetLatestProgress returns the most recent progress data and whether any
+// has been reported by the current stream
 func (s *StreamManager) GetLatestProgress() (progressData, bool) {
-	select {
-	case data := <-s.progressCh:
-		return data, true
-	default:
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.latest == nil {
 		return progressData{}, false
 	}
+	return *s.latest, true
 }
 
 // ValidateStartTimestamp validates that the start timestamp is not greater than file duration
@@ -469,6 +1485,83 @@ func (s *StreamManager) ValidateStartTimestamp(ctx context.Context, filePath, st
 	return s.validateStartTimestamp(ctx, filePath, startTimestamp)
 }
 
+// ValidateOutputDimensions probes the file and validates that its output
+// resolution can be streamed
+func (s *StreamManager) ValidateOutputDimensions(ctx context.Context, filePath string) error {
+	info := probeFile(ctx, s.logger, filePath)
+	return validateOutputDimensions(info.width, info.height)
+}
+
+// ValidateEntryOptions probes the file and checks that opts do not
+// contradict each other or the streams the file has
+func (s *StreamManager) ValidateEntryOptions(ctx context.Context, filePath string, opts EntryOptions) error {
+	if err := validateInputFormat(ctx, opts.InputFormat); err != nil {
+		return err
+	}
+	if err := validateMetadata(opts.Metadata); err != nil {
+		return err
+	}
+	if opts.Repeat < 0 {
+		return errors.New("repeat must not be negative")
+	}
+	if err := validateSubtitleEncoding(opts.SubtitleFile, opts.SubtitleEncoding); err != nil {
+		return err
+	}
+	if err := validateWatermark(opts.Overlay); err != nil {
+		return err
+	}
+	info := probeFile(ctx, s.logger, filePath)
+	if err := validateEntryConflicts(opts, info); err != nil {
+		return err
+	}
+	return s.validateFilterCount(s.preprocessingArgs(entry{File: filePath, EntryOptions: opts}, info))
+}
+
+// SetMaxFilters limits how many filters preprocessing may apply to one
+// entry. Values below 1 are treated as 1.
+func (s *StreamManager) SetMaxFilters(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxFilters = max(n, 1)
+}
+
+// validateFilterCount checks cfg against the configured filter limit
+func (s *StreamManager) validateFilterCount(cfg ffmpegArgs) error {
+	s.mu.RLock()
+	maxFilters := s.maxFilters
+	s.mu.RUnlock()
+	return validateFilterCount(cfg, maxFilters)
+}
+
+// ValidateReadable checks that path is a regular file the process can open
+// for reading. The error wraps fs.ErrNotExist or fs.ErrPermission so callers
+// can tell a missing file from one they may not read.
+func ValidateReadable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("file exists but is not readable: %w", err)
+		}
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	return nil
+}
+
+// VerifyFileIntegrity checks that the file is not still being written and,
+// in decode mode, that its tail decodes cleanly
+func (s *StreamManager) VerifyFileIntegrity(ctx context.Context, filePath, mode string) error {
+	return verifyFileIntegrity(ctx, filePath, mode)
+}
+
 // validateStartTimestamp validates that the start timestamp is not greater than file duration
 func (s *StreamManager) validateStartTimestamp(ctx context.Context, filePath, startTimestamp string) error {
 	if startTimestamp == "" {
@@ -495,6 +1588,86 @@ func (s *StreamManager) validateStartTimestamp(ctx context.Context, filePath, st
 	return nil
 }
 
+// validateEndTimestamp validates that the end timestamp falls after the start
+// timestamp and within the file duration
+func (s *StreamManager) validateEndTimestamp(ctx context.Context, filePath, startTimestamp, endTimestamp string) error {
+	if endTimestamp == "" {
+		return nil // No timestamp specified
+	}
+
+	endSeconds, err := parseTimestamp(endTimestamp)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp format: %w", err)
+	}
+
+	var startSeconds float64
+	if startTimestamp != "" {
+		if startSeconds, err = parseTimestamp(startTimestamp); err != nil {
+			return fmt.Errorf("invalid timestamp format: %w", err)
+		}
+	}
+	if endSeconds <= startSeconds {
+		return fmt.Errorf("end timestamp (%s) must be after the start timestamp (%.2fs)", endTimestamp, startSeconds)
+	}
+
+	duration, err := getFileDuration(ctx, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get file duration: %w", err)
+	}
+	if endSeconds > duration {
+		return fmt.Errorf("end timestamp (%s) is greater than file duration (%.2fs)", endTimestamp, duration)
+	}
+
+	return nil
+}
+
+// validateEntryConflicts checks for entry options that cannot be honoured
+// together or that need a stream the source does not have
+func validateEntryConflicts(opts EntryOptions, info fileProbeInfo) error {
+	if !info.hasAudio && !info.hasVideo {
+		return errors.New("source has neither an audio nor a video stream")
+	}
+	if opts.ImageFile != "" {
+		if opts.Mute {
+			return errors.New("image file conflicts with mute, the image is shown over the audio being dropped")
+		}
+		if !info.hasAudio {
+			return errors.New("image file requires a source with audio, the source has none")
+		}
+	}
+	// The generated black video never ends, only the audio stops the entry
+	if opts.Mute && info.audioOnly() {
+		return errors.New("mute conflicts with an audio-only source, nothing would be left to play")
+	}
+	return nil
+}
+
+// validateFades validates that fade durations are positive and fit within the
+// portion of the clip that will play. A zero duration means it is unknown.
+func validateFades(fadeIn, fadeOut float64, startTimestamp, endTimestamp string, duration float64) error {
+	if fadeIn < 0 || fadeOut < 0 {
+		return errors.New("fade durations must not be negative")
+	}
+
+	if fadeIn == 0 && fadeOut == 0 {
+		return nil
+	}
+
+	clip := clipDuration(startTimestamp, endTimestamp, duration)
+	if fadeOut > 0 && clip <= 0 {
+		return errors.New("fade out requires a known file duration or an end timestamp")
+	}
+
+	if clip <= 0 {
+		return nil
+	}
+
+	if fadeIn+fadeOut > clip {
+		return fmt.Errorf("fade in (%.2fs) and fade out (%.2fs) exceed the clip length (%.2fs)", fadeIn, fadeOut, clip)
+	}
+	return nil
+}
+
 // validateSubtitleFile validates that the subtitle file exists and has a supported format
 func (s *StreamManager) validateSubtitleFile(subtitleFile string) error {
 	if subtitleFile == "" {
@@ -516,3 +1689,71 @@ func (s *StreamManager) validateSubtitleFile(subtitleFile string) error {
 
 	return fmt.Errorf("unsupported subtitle format: %s (supported: %s)", ext, strings.Join(supportedExts, ", "))
 }
+
+// subtitleCharenc returns the character encoding to convert the entry's
+// subtitles from, empty when they are already UTF-8
+func (s *StreamManager) subtitleCharenc(e entry) string {
+	if e.SubtitleFile == "" {
+		return ""
+	}
+
+	charenc, err := subtitleCharenc(e.SubtitleFile, e.SubtitleEncoding)
+	if err != nil {
+		s.logger.Warn("Failed to detect subtitle encoding", zap.String("subtitleFile", e.SubtitleFile), zap.Error(err))
+		return ""
+	}
+	return charenc
+}
+
+// assSubtitleSize warns when an ASS/SSA script resolution differs from the
+// video, which renders subtitles at the wrong scale, and returns the
+// original_size to correct it with when ASSOriginalSize is enabled
+func (s *StreamManager) assSubtitleSize(subtitleFile string, probeInfo fileProbeInfo) string {
+	ext := strings.ToLower(filepath.Ext(subtitleFile))
+	if ext != ".ass" && ext != ".ssa" {
+		return ""
+	}
+
+	x, y, err := readASSPlayRes(subtitleFile)
+	if err != nil {
+		s.logger.Warn("Failed to read subtitle script resolution", zap.String("subtitleFile", subtitleFile), zap.Error(err))
+		return ""
+	}
+	if x == 0 || y == 0 || probeInfo.width == 0 || probeInfo.height == 0 {
+		return ""
+	}
+	if x == probeInfo.width && y == probeInfo.height {
+		return ""
+	}
+
+	s.logger.Warn("Subtitle script resolution differs from video resolution",
+		zap.String("subtitleFile", subtitleFile),
+		zap.String("scriptResolution", fmt.Sprintf("%dx%d", x, y)),
+		zap.String("videoResolution", fmt.Sprintf("%dx%d", probeInfo.width, probeInfo.height)),
+		zap.Bool("correcting", s.config.ASSOriginalSize))
+
+	if !s.config.ASSOriginalSize {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", x, y)
+}
+
+// validateImageFile validates that the still image exists and has a supported format
+func validateImageFile(imageFile string) error {
+	if imageFile == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(imageFile); os.IsNotExist(err) {
+		return fmt.Errorf("image file does not exist: %s", imageFile)
+	}
+
+	ext := strings.ToLower(filepath.Ext(imageFile))
+	supportedExts := []string{".png", ".jpg", ".jpeg", ".webp", ".bmp"}
+
+	if slices.Contains(supportedExts, ext) {
+		return nil
+	}
+
+	return fmt.Errorf("unsupported image format: %s (supported: %s)", ext, strings.Join(supportedExts, ", "))
+}
@@ -0,0 +1,203 @@
+package streammanager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxSubtitleSize bounds how much of a subtitle file is read to inspect it
+const maxSubtitleSize = 16 << 20
+
+// Character encodings detected in subtitle files
+const (
+	encodingUTF8        = "utf-8"
+	encodingUTF16LE     = "utf-16le"
+	encodingUTF16BE     = "utf-16be"
+	encodingWindows1252 = "windows-1252"
+	encodingLatin1      = "iso-8859-1"
+)
+
+// subtitleCuePatterns match the line that starts each cue, by extension
+var subtitleCuePatterns = map[string]*regexp.Regexp{
+	".srt": regexp.MustCompile(`(?m)^\s*\d+:\d{2}:\d{2}[,.]\d+\s*-->`),
+	".vtt": regexp.MustCompile(`(?m)^\s*(?:\d+:)?\d{2}:\d{2}\.\d+\s*-->`),
+	".ass": regexp.MustCompile(`(?m)^Dialogue:`),
+	".ssa": regexp.MustCompile(`(?m)^Dialogue:`),
+	".sub": regexp.MustCompile(`(?m)^\{\d+\}\{\d+\}`),
+	".sbv": regexp.MustCompile(`(?m)^\d+:\d{2}:\d{2}\.\d+,\d+:\d{2}:\d{2}\.\d+`),
+}
+
+// subtitleEncodingPattern matches a character encoding name as iconv takes
+// it, e.g. "CP1252" or "ISO-8859-15"
+var subtitleEncodingPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]*$`)
+
+// validateSubtitleEncoding checks an explicit subtitle encoding, which is
+// passed to the subtitles filter as charenc
+func validateSubtitleEncoding(subtitleFile, encoding string) error {
+	if encoding == "" {
+		return nil
+	}
+	if subtitleFile == "" {
+		return errors.New("subtitle encoding requires a subtitle file")
+	}
+	if len(encoding) > 32 || !subtitleEncodingPattern.MatchString(encoding) {
+		return fmt.Errorf("invalid subtitle encoding %q", encoding)
+	}
+	return nil
+}
+
+// subtitleCharenc returns the charenc the subtitles filter needs to read the
+// file as UTF-8: the explicit encoding when one is given, otherwise the
+// detected one. UTF-8 and UTF-16 with a byte order mark need none, ffmpeg
+// reads them as they are.
+func subtitleCharenc(path, encoding string) (string, error) {
+	if encoding != "" {
+		return encoding, nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if fi.Size() > maxSubtitleSize {
+		return "", fmt.Errorf("subtitle file is larger than %d bytes", maxSubtitleSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	switch detected := detectEncoding(data); detected {
+	case encodingWindows1252, encodingLatin1:
+		return detected, nil
+	}
+	return "", nil
+}
+
+// SubtitleInfo describes a subtitle file's format, encoding and contents
+type SubtitleInfo struct {
+	Format   string `json:"format"`
+	Encoding string `json:"encoding"`
+	UTF8     bool   `json:"utf8"` // Whether the text burns in without conversion
+	Cues     int    `json:"cues"`
+	Valid    bool   `json:"valid"`
+	Error    string `json:"error,omitempty"`
+}
+
+// InspectSubtitleFile detects the encoding of a subtitle file and counts its
+// cues. Problems with the contents are reported in the info rather than as
+// an error, which is only returned when the file cannot be read.
+func InspectSubtitleFile(path string) (SubtitleInfo, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	pattern, ok := subtitleCuePatterns[ext]
+	if !ok {
+		return SubtitleInfo{}, fmt.Errorf("unsupported subtitle format %q", ext)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return SubtitleInfo{}, err
+	}
+	if fi.Size() > maxSubtitleSize {
+		return SubtitleInfo{}, fmt.Errorf("subtitle file is larger than %d bytes", maxSubtitleSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SubtitleInfo{}, err
+	}
+
+	info := SubtitleInfo{
+		Format:   strings.TrimPrefix(ext, "."),
+		Encoding: detectEncoding(data),
+	}
+	info.UTF8 = info.Encoding == encodingUTF8
+
+	text, err := decodeSubtitle(data, info.Encoding)
+	if err != nil {
+		info.Error = err.Error()
+		return info, nil
+	}
+	if ext == ".vtt" && !strings.HasPrefix(text, "WEBVTT") {
+		info.Error = "missing WEBVTT header"
+		return info, nil
+	}
+
+	info.Cues = len(pattern.FindAllStringIndex(text, -1))
+	if info.Cues == 0 {
+		info.Error = "no cues found"
+		return info, nil
+	}
+	info.Valid = true
+	return info, nil
+}
+
+// detectEncoding guesses the character encoding of subtitle text from its
+// byte order mark, or whether it is valid UTF-8. Other text is taken as
+// Windows-1252 when it uses that code page's printable C1 range, and as
+// Latin-1 otherwise.
+func detectEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return encodingUTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return encodingUTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return encodingUTF16BE
+	case utf8.Valid(data):
+		return encodingUTF8
+	}
+	for _, b := range data {
+		if b >= 0x80 && b <= 0x9F {
+			return encodingWindows1252
+		}
+	}
+	return encodingLatin1
+}
+
+// windows1252 maps the bytes 0x80 to 0x9F of Windows-1252, where it differs
+// from Latin-1. Unassigned bytes map to U+FFFD.
+var windows1252 = [32]rune{
+	'€', '\ufffd', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\ufffd', 'Ž', '\ufffd',
+	'\ufffd', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\ufffd', 'ž', 'Ÿ',
+}
+
+// decodeSubtitle converts subtitle text in the given encoding to UTF-8
+func decodeSubtitle(data []byte, encoding string) (string, error) {
+	switch encoding {
+	case encodingUTF8:
+		return string(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})), nil
+	case encodingUTF16LE, encodingUTF16BE:
+		data = data[2:]
+		if len(data)%2 != 0 {
+			return "", fmt.Errorf("truncated %s text", encoding)
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if encoding == encodingUTF16LE {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+		return string(utf16.Decode(units)), nil
+	case encodingWindows1252, encodingLatin1:
+		var sb strings.Builder
+		sb.Grow(len(data))
+		for _, b := range data {
+			if encoding == encodingWindows1252 && b >= 0x80 && b <= 0x9F {
+				sb.WriteRune(windows1252[b-0x80])
+				continue
+			}
+			sb.WriteRune(rune(b))
+		}
+		return sb.String(), nil
+	}
+	return "", fmt.Errorf("unsupported subtitle encoding %q", encoding)
+}
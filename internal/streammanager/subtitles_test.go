@@ -0,0 +1,211 @@
+package streammanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInspectSubtitleFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  []byte
+		encoding string
+		cues     int
+		valid    bool
+	}{
+		{
+			name:     "utf-8 srt",
+			file:     "movie.srt",
+			content:  []byte("1\n00:00:01,000 --> 00:00:02,000\nCafé\n\n2\n00:00:03,000 --> 00:00:04,000\nBye\n"),
+			encoding: encodingUTF8,
+			cues:     2,
+			valid:    true,
+		},
+		{
+			name:     "latin-1 srt",
+			file:     "movie.srt",
+			content:  []byte("1\n00:00:01,000 --> 00:00:02,000\nCaf\xe9\n"),
+			encoding: encodingLatin1,
+			cues:     1,
+			valid:    true,
+		},
+		{
+			name:     "windows-1252 srt",
+			file:     "movie.srt",
+			content:  []byte("1\n00:00:01,000 --> 00:00:02,000\n\x93Quoted\x94\n"),
+			encoding: encodingWindows1252,
+			cues:     1,
+			valid:    true,
+		},
+		{
+			name:     "utf-16le srt",
+			file:     "movie.srt",
+			content:  utf16le("1\n00:00:01,000 --> 00:00:02,000\nHi\n"),
+			encoding: encodingUTF16LE,
+			cues:     1,
+			valid:    true,
+		},
+		{
+			name:     "vtt",
+			file:     "movie.vtt",
+			content:  []byte("WEBVTT\n\n00:01.000 --> 00:02.000\nHi\n"),
+			encoding: encodingUTF8,
+			cues:     1,
+			valid:    true,
+		},
+		{
+			name:     "vtt without header",
+			file:     "movie.vtt",
+			content:  []byte("00:01.000 --> 00:02.000\nHi\n"),
+			encoding: encodingUTF8,
+		},
+		{
+			name:     "ass",
+			file:     "movie.ass",
+			content:  []byte("[Events]\nFormat: Layer, Start, End, Text\nDialogue: 0,0:00:01.00,0:00:02.00,Hi\nDialogue: 0,0:00:03.00,0:00:04.00,Bye\n"),
+			encoding: encodingUTF8,
+			cues:     2,
+			valid:    true,
+		},
+		{
+			name:     "srt without cues",
+			file:     "movie.srt",
+			content:  []byte("not a subtitle\n"),
+			encoding: encodingUTF8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, tt.content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := InspectSubtitleFile(path)
+			if err != nil {
+				t.Fatalf("InspectSubtitleFile() error = %v", err)
+			}
+			if info.Encoding != tt.encoding {
+				t.Errorf("Encoding = %q, want %q", info.Encoding, tt.encoding)
+			}
+			if info.Cues != tt.cues {
+				t.Errorf("Cues = %d, want %d", info.Cues, tt.cues)
+			}
+			if info.Valid != tt.valid {
+				t.Errorf("Valid = %v, want %v (error %q)", info.Valid, tt.valid, info.Error)
+			}
+			if !tt.valid && info.Error == "" {
+				t.Error("Error is empty for an invalid subtitle file")
+			}
+		})
+	}
+}
+
+func TestInspectSubtitleFileUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "movie.txt")
+	if err := os.WriteFile(path, []byte("text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InspectSubtitleFile(path); err == nil {
+		t.Error("InspectSubtitleFile() with a .txt file = nil, want error")
+	}
+	if _, err := InspectSubtitleFile(filepath.Join(t.TempDir(), "missing.srt")); err == nil {
+		t.Error("InspectSubtitleFile() with a missing file = nil, want error")
+	}
+}
+
+func TestDecodeSubtitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+		want     string
+	}{
+		{"utf-8 with bom", []byte("\xef\xbb\xbfCafé"), encodingUTF8, "Café"},
+		{"latin-1", []byte("Caf\xe9"), encodingLatin1, "Café"},
+		{"windows-1252", []byte("\x93Hi\x94 \x80"), encodingWindows1252, "“Hi” €"},
+		{"utf-16le", utf16le("Café"), encodingUTF16LE, "Café"},
+		{"utf-16be", []byte{0xFE, 0xFF, 0x00, 'H', 0x00, 'i'}, encodingUTF16BE, "Hi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeSubtitle(tt.data, tt.encoding)
+			if err != nil {
+				t.Fatalf("decodeSubtitle() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("decodeSubtitle() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtitleCharenc(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  []byte
+		encoding string
+		want     string
+	}{
+		{name: "utf-8", content: []byte("1\n00:00:01,000 --> 00:00:02,000\nCafé\n")},
+		{name: "latin-1", content: []byte("1\n00:00:01,000 --> 00:00:02,000\nCaf\xe9\n"), want: encodingLatin1},
+		{name: "windows-1252", content: []byte("1\n00:00:01,000 --> 00:00:02,000\n\x93Hi\x94\n"), want: encodingWindows1252},
+		{name: "utf-16 read natively", content: utf16le("1\n00:00:01,000 --> 00:00:02,000\nHi\n")},
+		{name: "explicit encoding", content: []byte("1\n00:00:01,000 --> 00:00:02,000\nCaf\xe9\n"), encoding: "ISO-8859-15", want: "ISO-8859-15"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "movie.srt")
+			if err := os.WriteFile(path, tt.content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := subtitleCharenc(path, tt.encoding)
+			if err != nil {
+				t.Fatalf("subtitleCharenc() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("subtitleCharenc() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSubtitleEncoding(t *testing.T) {
+	tests := []struct {
+		name         string
+		subtitleFile string
+		encoding     string
+		wantErr      bool
+	}{
+		{name: "unset"},
+		{name: "detected", subtitleFile: "movie.srt"},
+		{name: "explicit", subtitleFile: "movie.srt", encoding: "CP1252"},
+		{name: "no subtitle file", encoding: "CP1252", wantErr: true},
+		{name: "filter injection", subtitleFile: "movie.srt", encoding: "latin1,drawtext=text=x", wantErr: true},
+		{name: "quote", subtitleFile: "movie.srt", encoding: "latin1'", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSubtitleEncoding(tt.subtitleFile, tt.encoding)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSubtitleEncoding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// utf16le encodes s as UTF-16LE with a byte order mark
+func utf16le(s string) []byte {
+	data := []byte{0xFF, 0xFE}
+	for _, r := range s {
+		data = append(data, byte(r), byte(r>>8))
+	}
+	return data
+}
@@ -0,0 +1,47 @@
+package streammanager
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// How the streaming ffmpeg treats the timestamps it reads from the FIFO.
+// Streaming copies packets, so only demuxer flags apply; filters such as
+// aresample=async or -fps_mode need a re-encode and have no effect there.
+const (
+	// TimestampIgnore discards DTS and derives it from PTS. Use it for
+	// sources whose DTS goes backwards, which otherwise fail with
+	// non-monotonic DTS errors. It is the default.
+	TimestampIgnore = "ignore"
+	// TimestampGenerate keeps DTS and fills in missing PTS from it. Use it
+	// when ignore causes audio and video to drift apart, for sources with
+	// sound DTS but gaps in PTS.
+	TimestampGenerate = "generate"
+	// TimestampPassthrough keeps timestamps exactly as preprocessing wrote
+	// them. Use it when every source is well formed.
+	TimestampPassthrough = "passthrough"
+)
+
+var timestampModes = []string{TimestampIgnore, TimestampGenerate, TimestampPassthrough}
+
+// validateTimestampMode checks the timestamp mode is known
+func validateTimestampMode(mode string) error {
+	if mode != "" && !slices.Contains(timestampModes, mode) {
+		return fmt.Errorf("unsupported timestamp mode %q, must be one of %s",
+			mode, strings.Join(timestampModes, ", "))
+	}
+	return nil
+}
+
+// timestampArgs returns the streaming ffmpeg's arguments for the mode
+func timestampArgs(mode string) []string {
+	switch mode {
+	case TimestampGenerate:
+		return []string{"-fflags", "+genpts"}
+	case TimestampPassthrough:
+		return nil
+	default:
+		return []string{"-fflags", "+igndts"}
+	}
+}
@@ -0,0 +1,110 @@
+package streammanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// FFmpegVersion is the release version of the ffmpeg binary in use. Major and
+// Minor are zero for development builds, which report a git revision instead.
+type FFmpegVersion struct {
+	Raw   string `json:"raw"`
+	Major int    `json:"major"`
+	Minor int    `json:"minor"`
+}
+
+// minFFmpegVersion is the oldest release with every flag the builders use;
+// -fps_mode was added in 5.1
+var minFFmpegVersion = FFmpegVersion{Raw: "5.1", Major: 5, Minor: 1}
+
+var (
+	ffmpegVersionMu sync.Mutex
+	ffmpegVersion   FFmpegVersion
+)
+
+// ffmpegVersionRegex matches the first line of ffmpeg -version
+var ffmpegVersionRegex = regexp.MustCompile(`^ffmpeg version (\S+)`)
+
+// releaseVersionRegex matches release versions such as 6.1.1, n7.0 or 4.4.2-0ubuntu
+var releaseVersionRegex = regexp.MustCompile(`^n?(\d+)\.(\d+)`)
+
+// DetectFFmpegVersion runs ffmpeg -version and parses the reported version
+func DetectFFmpegVersion(ctx context.Context) (FFmpegVersion, error) {
+	output, err := exec.CommandContext(ctx, "ffmpeg", "-version").Output()
+	if err != nil {
+		return FFmpegVersion{}, fmt.Errorf("failed to run ffmpeg -version: %w", err)
+	}
+	return parseFFmpegVersion(string(output))
+}
+
+// parseFFmpegVersion extracts the version from ffmpeg -version output
+func parseFFmpegVersion(output string) (FFmpegVersion, error) {
+	match := ffmpegVersionRegex.FindStringSubmatch(output)
+	if match == nil {
+		return FFmpegVersion{}, errors.New("unrecognized ffmpeg -version output")
+	}
+
+	v := FFmpegVersion{Raw: match[1]}
+	if release := releaseVersionRegex.FindStringSubmatch(v.Raw); release != nil {
+		v.Major, _ = strconv.Atoi(release[1])
+		v.Minor, _ = strconv.Atoi(release[2])
+	}
+	return v, nil
+}
+
+// IsRelease reports whether the version is a numbered release
+func (v FFmpegVersion) IsRelease() bool {
+	return v.Major > 0
+}
+
+// AtLeast reports whether v is other or newer. Development builds are
+// assumed to be newer than any release.
+func (v FFmpegVersion) AtLeast(other FFmpegVersion) bool {
+	if !v.IsRelease() {
+		return true
+	}
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	return v.Minor >= other.Minor
+}
+
+// Supported reports whether v has every flag the builders use
+func (v FFmpegVersion) Supported() bool {
+	return v.AtLeast(minFFmpegVersion)
+}
+
+// MinFFmpegVersion returns the oldest ffmpeg release that needs no legacy
+// flag substitutions
+func MinFFmpegVersion() FFmpegVersion {
+	return minFFmpegVersion
+}
+
+// SetFFmpegVersion records the version of the ffmpeg binary so that argument
+// builders can substitute legacy flags for older releases
+func SetFFmpegVersion(v FFmpegVersion) {
+	ffmpegVersionMu.Lock()
+	defer ffmpegVersionMu.Unlock()
+	ffmpegVersion = v
+}
+
+// CurrentFFmpegVersion returns the version recorded by SetFFmpegVersion
+func CurrentFFmpegVersion() FFmpegVersion {
+	ffmpegVersionMu.Lock()
+	defer ffmpegVersionMu.Unlock()
+	return ffmpegVersion
+}
+
+// fpsModeArgs returns the flag that passes frame timestamps through
+// unchanged. ffmpeg before 5.1 only has the deprecated -vsync.
+func fpsModeArgs(legacy bool) []string {
+	if legacy {
+		return []string{"-vsync", "vfr"}
+	}
+	return []string{"-fps_mode", "vfr"}
+}
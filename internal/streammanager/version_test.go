@@ -0,0 +1,78 @@
+package streammanager
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseFFmpegVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		expected  FFmpegVersion
+		supported bool
+		expectErr bool
+	}{
+		{
+			name:      "release",
+			output:    "ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13\n",
+			expected:  FFmpegVersion{Raw: "6.1.1", Major: 6, Minor: 1},
+			supported: true,
+		},
+		{
+			name:      "distro package",
+			output:    "ffmpeg version 4.4.2-0ubuntu0.22.04.1 Copyright (c) 2000-2021 the FFmpeg developers\n",
+			expected:  FFmpegVersion{Raw: "4.4.2-0ubuntu0.22.04.1", Major: 4, Minor: 4},
+			supported: false,
+		},
+		{
+			name:      "tagged build",
+			output:    "ffmpeg version n5.1 Copyright (c) 2000-2022 the FFmpeg developers\n",
+			expected:  FFmpegVersion{Raw: "n5.1", Major: 5, Minor: 1},
+			supported: true,
+		},
+		{
+			name:      "development build",
+			output:    "ffmpeg version N-112345-g0123456789 Copyright (c) 2000-2024 the FFmpeg developers\n",
+			expected:  FFmpegVersion{Raw: "N-112345-g0123456789"},
+			supported: true,
+		},
+		{
+			name:      "not ffmpeg",
+			output:    "avconv version 12.3\n",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := parseFFmpegVersion(tt.output)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("parseFFmpegVersion() = %+v, want error", v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFFmpegVersion() error = %v", err)
+			}
+			if v != tt.expected {
+				t.Errorf("parseFFmpegVersion() = %+v, want %+v", v, tt.expected)
+			}
+			if v.Supported() != tt.supported {
+				t.Errorf("Supported() = %v, want %v", v.Supported(), tt.supported)
+			}
+		})
+	}
+}
+
+func TestLegacyFPSMode(t *testing.T) {
+	args := buildPreprocessingArgs(ffmpegArgs{
+		source:        "/path/to/video.mp4",
+		overlay:       OverlaySettings{ShowFilename: true},
+		legacyFPSMode: true,
+	})
+	if !slices.Contains(args, "-vsync") || slices.Contains(args, "-fps_mode") {
+		t.Errorf("buildPreprocessingArgs() with legacy fps mode = %v, want -vsync instead of -fps_mode", args)
+	}
+}
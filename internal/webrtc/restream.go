@@ -0,0 +1,197 @@
+package webrtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v4"
+	"go.uber.org/zap"
+)
+
+// OnBroadcastStart registers fn to be called whenever a WHIP broadcast is
+// established on the default channel
+func (s *Server) OnBroadcastStart(fn func()) {
+	s.channelsMu.Lock()
+	defer s.channelsMu.Unlock()
+	s.onStart = append(s.onStart, fn)
+}
+
+// Restream forwards the default channel's WHIP broadcast to destination by
+// feeding its RTP packets to an ffmpeg process. It blocks until ctx is
+// cancelled, the broadcast ends, or ffmpeg exits.
+func (s *Server) Restream(ctx context.Context, destination string) error {
+	b, _ := s.lookupChannel(defaultChannel)
+
+	b.mu.RLock()
+	broadcasting := b.peerConnection != nil
+	b.mu.RUnlock()
+	if !broadcasting {
+		return errors.New("no active broadcast")
+	}
+
+	videoPort, err := freeUDPPort()
+	if err != nil {
+		return err
+	}
+	audioPort, err := freeUDPPort()
+	if err != nil {
+		return err
+	}
+
+	sdpFile, err := os.CreateTemp("", "streammanager-restream-*.sdp")
+	if err != nil {
+		return fmt.Errorf("failed to create sdp file: %w", err)
+	}
+	defer os.Remove(sdpFile.Name())
+
+	if _, err := sdpFile.WriteString(restreamSDP(videoPort, audioPort)); err != nil {
+		sdpFile.Close()
+		return fmt.Errorf("failed to write sdp file: %w", err)
+	}
+	if err := sdpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write sdp file: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-protocol_whitelist", "file,udp,rtp",
+		"-rw_timeout", "5000000", // exit once the broadcast stops sending packets
+		"-i", sdpFile.Name(),
+		"-c:v", "copy",
+		"-c:a", "aac", "-b:a", "128k", "-ac", "2",
+		"-f", "flv",
+		"-flvflags", "no_duration_filesize",
+		destination)
+
+	var stderrBuf strings.Builder
+	cmd.Stderr = &stderrBuf
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	videoConn, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", videoPort))
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return fmt.Errorf("failed to dial video port: %w", err)
+	}
+	defer videoConn.Close()
+
+	audioConn, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", audioPort))
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return fmt.Errorf("failed to dial audio port: %w", err)
+	}
+	defer audioConn.Close()
+
+	b.addSink(webrtc.RTPCodecTypeVideo, videoConn)
+	b.addSink(webrtc.RTPCodecTypeAudio, audioConn)
+	defer b.removeSink(videoConn)
+	defer b.removeSink(audioConn)
+
+	// Ask the publisher for a keyframe so ffmpeg can start decoding right away
+	b.requestKeyframe()
+
+	s.logger.Info("Restreaming WHIP broadcast", zap.String("destination", destination))
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		stderrOutput := strings.TrimSpace(stderrBuf.String())
+		if stderrOutput != "" {
+			return fmt.Errorf("ffmpeg failed: %w\nFFmpeg stderr: %s", err, stderrOutput)
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return nil
+}
+
+// Payload types restreamSDP declares. Forwarded packets are rewritten to
+// them, since the publisher may have negotiated others.
+const (
+	restreamVideoPayloadType = 96
+	restreamAudioPayloadType = 111
+)
+
+// restreamSDP describes the H264 and Opus RTP streams forwarded to ffmpeg
+func restreamSDP(videoPort, audioPort int) string {
+	return fmt.Sprintf(`v=0
+o=- 0 0 IN IP4 127.0.0.1
+s=WHIP restream
+c=IN IP4 127.0.0.1
+t=0 0
+m=video %[1]d RTP/AVP %[2]d
+a=rtpmap:%[2]d H264/90000
+a=fmtp:%[2]d packetization-mode=1
+m=audio %[3]d RTP/AVP %[4]d
+a=rtpmap:%[4]d opus/48000/2
+`, videoPort, restreamVideoPayloadType, audioPort, restreamAudioPayloadType)
+}
+
+// freeUDPPort finds a local UDP port that is currently unused
+func freeUDPPort() (int, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find free udp port: %w", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port, nil
+}
+
+func (b *Broadcaster) addSink(kind webrtc.RTPCodecType, w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sinks[w] = kind
+}
+
+func (b *Broadcaster) removeSink(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sinks, w)
+}
+
+// writeSinks copies an RTP packet to every sink registered for kind. The
+// packet's payload type is rewritten in place to the one restreamSDP
+// declares for kind, keeping the marker bit.
+func (b *Broadcaster) writeSinks(kind webrtc.RTPCodecType, pkt []byte) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(b.sinks) == 0 || len(pkt) < 2 {
+		return
+	}
+	payloadType := byte(restreamVideoPayloadType)
+	if kind == webrtc.RTPCodecTypeAudio {
+		payloadType = restreamAudioPayloadType
+	}
+	pkt[1] = pkt[1]&0x80 | payloadType
+
+	for w, k := range b.sinks {
+		if k == kind {
+			_, _ = w.Write(pkt)
+		}
+	}
+}
+
+// requestKeyframe sends a picture loss indication to the publisher
+func (b *Broadcaster) requestKeyframe() {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.peerConnection == nil || b.videoSSRC == 0 {
+		return
+	}
+	_ = b.peerConnection.WriteRTCP([]rtcp.Packet{
+		&rtcp.PictureLossIndication{MediaSSRC: b.videoSSRC},
+	})
+}
@@ -0,0 +1,90 @@
+package webrtc
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v4"
+	"go.uber.org/zap"
+)
+
+func newTestServer(t *testing.T, opts ...Option) *Server {
+	t.Helper()
+	s, err := NewServer(zap.NewNop(), opts...)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	return s
+}
+
+func TestRestreamSDP(t *testing.T) {
+	sdp := restreamSDP(5004, 5006)
+
+	for _, want := range []string{
+		"m=video 5004 RTP/AVP 96",
+		"a=rtpmap:96 H264/90000",
+		"a=fmtp:96 packetization-mode=1",
+		"m=audio 5006 RTP/AVP 111",
+		"a=rtpmap:111 opus/48000/2",
+	} {
+		if !strings.Contains(sdp, want) {
+			t.Errorf("restreamSDP() = %q, missing %q", sdp, want)
+		}
+	}
+}
+
+// rtpPacket returns an RTP packet with the payload type, marker bit and
+// payload given
+func rtpPacket(payloadType byte, marker bool, payload string) []byte {
+	pkt := make([]byte, 12, 12+len(payload))
+	pkt[0] = 0x80 // Version 2
+	pkt[1] = payloadType
+	if marker {
+		pkt[1] |= 0x80
+	}
+	return append(pkt, payload...)
+}
+
+func TestWriteSinks(t *testing.T) {
+	b := newBroadcaster()
+	var video, audio bytes.Buffer
+	b.addSink(webrtc.RTPCodecTypeVideo, &video)
+	b.addSink(webrtc.RTPCodecTypeAudio, &audio)
+
+	// Payload types the publisher negotiated are rewritten to the SDP's
+	b.writeSinks(webrtc.RTPCodecTypeVideo, rtpPacket(102, true, "video"))
+	b.writeSinks(webrtc.RTPCodecTypeAudio, rtpPacket(109, false, "audio"))
+	if want := rtpPacket(restreamVideoPayloadType, true, "video"); !bytes.Equal(video.Bytes(), want) {
+		t.Errorf("video sink got %x, want %x", video.Bytes(), want)
+	}
+	if want := rtpPacket(restreamAudioPayloadType, false, "audio"); !bytes.Equal(audio.Bytes(), want) {
+		t.Errorf("audio sink got %x, want %x", audio.Bytes(), want)
+	}
+
+	b.removeSink(&video)
+	video.Reset()
+	b.writeSinks(webrtc.RTPCodecTypeVideo, rtpPacket(102, false, "again"))
+	if video.Len() != 0 {
+		t.Errorf("removed sink got %x, want no more packets", video.Bytes())
+	}
+}
+
+func TestRestreamWithoutBroadcast(t *testing.T) {
+	s := newTestServer(t)
+
+	if err := s.Restream(context.Background(), "rtmp://localhost/live/test"); err == nil {
+		t.Error("Restream() with no broadcast = nil, want error")
+	}
+}
+
+func TestOnBroadcastStart(t *testing.T) {
+	s := newTestServer(t)
+	s.OnBroadcastStart(func() {})
+	s.OnBroadcastStart(func() {})
+
+	if len(s.onStart) != 2 {
+		t.Errorf("OnBroadcastStart() registered %d hooks, want 2", len(s.onStart))
+	}
+}
@@ -4,19 +4,89 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/pion/interceptor"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v4"
 	"go.uber.org/zap"
 )
 
 type Server struct {
-	logger      *zap.Logger
-	api         *webrtc.API
-	broadcaster *Broadcaster
-	mu          sync.RWMutex
+	logger          *zap.Logger
+	api             *webrtc.API
+	channels        map[string]*Broadcaster
+	channelsMu      sync.RWMutex
+	onStart         []func()
+	subscriberGrace time.Duration
+	maxVideoBitrate int // kbps advertised to WHEP subscribers, 0 for no hint
+	interfaces      []string
+	nat1To1IPs      []string
+	udpPortMin      uint16
+	udpPortMax      uint16
+	mu              sync.RWMutex
+}
+
+// defaultChannel is the channel served by the bare /whip and /whep endpoints
+const defaultChannel = "default"
+
+// channelNameRegex matches valid channel names used as a path segment
+var channelNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// defaultSubscriberGrace is how long a failed or disconnected subscriber is
+// given to recover before it is removed
+const defaultSubscriberGrace = 5 * time.Second
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithSubscriberGrace sets how long a failed or disconnected WHEP subscriber
+// may take to recover before it is removed. Zero removes it immediately.
+func WithSubscriberGrace(d time.Duration) Option {
+	return func(s *Server) {
+		s.subscriberGrace = d
+	}
+}
+
+// WithMaxVideoBitrate advertises kbps as the video bandwidth (b=AS) in WHEP
+// answers, hinting subscribers to keep their receive estimate below it. Zero
+// leaves the answer without a hint.
+func WithMaxVideoBitrate(kbps int) Option {
+	return func(s *Server) {
+		s.maxVideoBitrate = kbps
+	}
+}
+
+// WithInterfaces restricts ICE candidate gathering to the named network
+// interfaces, so internal addresses of a multi-homed host are not advertised
+func WithInterfaces(names []string) Option {
+	return func(s *Server) {
+		s.interfaces = names
+	}
+}
+
+// WithNAT1To1IPs advertises ips in place of the host's own addresses, for
+// servers behind a 1:1 NAT with a known public IP
+func WithNAT1To1IPs(ips []string) Option {
+	return func(s *Server) {
+		s.nat1To1IPs = ips
+	}
+}
+
+// WithUDPPortRange limits ICE to UDP ports between portMin and portMax, so
+// only that range needs opening in a firewall. Every peer connection takes a
+// port on each interface left by WithInterfaces, so size the range for
+// interfaces × concurrent publishers and viewers. With WithNAT1To1IPs the
+// same ports are advertised on the public IP and must be forwarded as is.
+func WithUDPPortRange(portMin, portMax uint16) Option {
+	return func(s *Server) {
+		s.udpPortMin = portMin
+		s.udpPortMax = portMax
+	}
 }
 
 type Broadcaster struct {
@@ -24,10 +94,21 @@ type Broadcaster struct {
 	videoTrack     *webrtc.TrackLocalStaticRTP
 	audioTrack     *webrtc.TrackLocalStaticRTP
 	subscribers    map[string]*webrtc.PeerConnection
+	pendingRemoval map[string]*time.Timer
+	sinks          map[io.Writer]webrtc.RTPCodecType
+	videoSSRC      uint32
 	mu             sync.RWMutex
 }
 
-func NewServer(logger *zap.Logger) (*Server, error) {
+func newBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		subscribers:    make(map[string]*webrtc.PeerConnection),
+		pendingRemoval: make(map[string]*time.Timer),
+		sinks:          make(map[io.Writer]webrtc.RTPCodecType),
+	}
+}
+
+func NewServer(logger *zap.Logger, opts ...Option) (*Server, error) {
 	// Create a MediaEngine object to configure the supported codec
 	m := &webrtc.MediaEngine{}
 
@@ -52,28 +133,127 @@ func NewServer(logger *zap.Logger) (*Server, error) {
 		return nil, fmt.Errorf("failed to register default interceptors: %w", err)
 	}
 
+	s := &Server{
+		logger:          logger,
+		subscriberGrace: defaultSubscriberGrace,
+		channels:        map[string]*Broadcaster{defaultChannel: newBroadcaster()},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	settings, err := s.settingEngine()
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the API object with the MediaEngine
-	api := webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(i))
+	s.api = webrtc.NewAPI(
+		webrtc.WithMediaEngine(m),
+		webrtc.WithInterceptorRegistry(i),
+		webrtc.WithSettingEngine(settings))
 
-	return &Server{
-		logger: logger,
-		api:    api,
-		broadcaster: &Broadcaster{
-			subscribers: make(map[string]*webrtc.PeerConnection),
-		},
-	}, nil
+	return s, nil
+}
+
+// settingEngine applies the ICE options to a SettingEngine
+func (s *Server) settingEngine() (webrtc.SettingEngine, error) {
+	var settings webrtc.SettingEngine
+
+	if len(s.interfaces) > 0 {
+		allowed := slices.Clone(s.interfaces)
+		settings.SetInterfaceFilter(func(name string) bool {
+			return slices.Contains(allowed, name)
+		})
+	}
+
+	if len(s.nat1To1IPs) > 0 {
+		settings.SetNAT1To1IPs(s.nat1To1IPs, webrtc.ICECandidateTypeHost)
+	}
+
+	if s.udpPortMin != 0 || s.udpPortMax != 0 {
+		if err := settings.SetEphemeralUDPPortRange(s.udpPortMin, s.udpPortMax); err != nil {
+			return settings, fmt.Errorf("invalid UDP port range %d-%d: %w", s.udpPortMin, s.udpPortMax, err)
+		}
+	}
+
+	return settings, nil
 }
 
 func (s *Server) SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/whip", s.handleWHIP)
+	mux.HandleFunc("/whip/", s.handleWHIP)
 	mux.HandleFunc("/whep", s.handleWHEP)
+	mux.HandleFunc("/whep/", s.handleWHEP)
+}
+
+// channelFromPath returns the channel named by the path segment after prefix,
+// or the default channel for the bare endpoint
+func channelFromPath(path, prefix string) (string, bool) {
+	name := strings.TrimPrefix(strings.TrimPrefix(path, prefix), "/")
+	if name == "" {
+		return defaultChannel, true
+	}
+	return name, channelNameRegex.MatchString(name)
+}
+
+// channel returns the named channel, creating it if needed
+func (s *Server) channel(name string) *Broadcaster {
+	s.channelsMu.Lock()
+	defer s.channelsMu.Unlock()
+
+	b, ok := s.channels[name]
+	if !ok {
+		b = newBroadcaster()
+		s.channels[name] = b
+	}
+	return b
+}
+
+// lookupChannel returns the named channel if it exists
+func (s *Server) lookupChannel(name string) (*Broadcaster, bool) {
+	s.channelsMu.RLock()
+	defer s.channelsMu.RUnlock()
+
+	b, ok := s.channels[name]
+	return b, ok
+}
+
+// removeChannelIfIdle drops a non-default channel once it has no broadcast
+// and no subscribers
+func (s *Server) removeChannelIfIdle(name string) {
+	if name == defaultChannel {
+		return
+	}
+
+	s.channelsMu.Lock()
+	defer s.channelsMu.Unlock()
+
+	b, ok := s.channels[name]
+	if !ok {
+		return
+	}
+
+	b.mu.RLock()
+	idle := b.peerConnection == nil && len(b.subscribers) == 0
+	b.mu.RUnlock()
+	if idle {
+		delete(s.channels, name)
+	}
 }
 
 // WHIP endpoint - WebRTC-HTTP Ingestion Protocol for streaming to server
 func (s *Server) handleWHIP(w http.ResponseWriter, r *http.Request) {
+	name, ok := channelFromPath(r.URL.Path, "/whip")
+	if !ok {
+		http.Error(w, "Invalid channel name", http.StatusBadRequest)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodPost:
-		s.handleWHIPOffer(w, r)
+		s.handleWHIPOffer(w, r, name)
 	case http.MethodOptions:
 		s.handleWHIPOptions(w, r)
 	default:
@@ -88,14 +268,30 @@ func (s *Server) handleWHIPOptions(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) handleWHIPOffer(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleWHIPOffer(w http.ResponseWriter, r *http.Request, name string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	b := s.channel(name)
+	logger := s.logger.With(zap.String("channel", name))
+
+	// A failed offer must not leave its peer connection or a new channel behind
+	var peerConnection *webrtc.PeerConnection
+	established := false
+	defer func() {
+		if established {
+			return
+		}
+		if peerConnection != nil {
+			_ = peerConnection.Close()
+		}
+		s.removeChannelIfIdle(name)
+	}()
+
 	// Read the SDP offer
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		s.logger.Error("Failed to read request body", zap.Error(err))
+		logger.Error("Failed to read request body", zap.Error(err))
 		http.Error(w, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
@@ -106,7 +302,7 @@ func (s *Server) handleWHIPOffer(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create a new RTCPeerConnection
-	peerConnection, err := s.api.NewPeerConnection(webrtc.Configuration{
+	peerConnection, err = s.api.NewPeerConnection(webrtc.Configuration{
 		ICEServers: []webrtc.ICEServer{
 			{
 				URLs: []string{"stun:stun.l.google.com:19302"},
@@ -114,7 +310,7 @@ func (s *Server) handleWHIPOffer(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 	if err != nil {
-		s.logger.Error("Failed to create peer connection", zap.Error(err))
+		logger.Error("Failed to create peer connection", zap.Error(err))
 		http.Error(w, "Failed to create peer connection", http.StatusInternalServerError)
 		return
 	}
@@ -123,7 +319,7 @@ func (s *Server) handleWHIPOffer(w http.ResponseWriter, r *http.Request) {
 	if _, err = peerConnection.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{
 		Direction: webrtc.RTPTransceiverDirectionRecvonly,
 	}); err != nil {
-		s.logger.Error("Failed to add video transceiver", zap.Error(err))
+		logger.Error("Failed to add video transceiver", zap.Error(err))
 		http.Error(w, "Failed to add video transceiver", http.StatusInternalServerError)
 		return
 	}
@@ -131,14 +327,14 @@ func (s *Server) handleWHIPOffer(w http.ResponseWriter, r *http.Request) {
 	if _, err = peerConnection.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
 		Direction: webrtc.RTPTransceiverDirectionRecvonly,
 	}); err != nil {
-		s.logger.Error("Failed to add audio transceiver", zap.Error(err))
+		logger.Error("Failed to add audio transceiver", zap.Error(err))
 		http.Error(w, "Failed to add audio transceiver", http.StatusInternalServerError)
 		return
 	}
 
 	// Set up track handling
 	peerConnection.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
-		s.logger.Info("New track received",
+		logger.Info("New track received",
 			zap.String("codec", track.Codec().MimeType),
 			zap.String("track_id", track.ID()))
 
@@ -149,39 +345,57 @@ func (s *Server) handleWHIPOffer(w http.ResponseWriter, r *http.Request) {
 		if track.Kind() == webrtc.RTPCodecTypeVideo {
 			localTrack, err = webrtc.NewTrackLocalStaticRTP(track.Codec().RTPCodecCapability, "video", "broadcast")
 			if err != nil {
-				s.logger.Error("Failed to create local video track", zap.Error(err))
+				logger.Error("Failed to create local video track", zap.Error(err))
 				return
 			}
-			s.broadcaster.videoTrack = localTrack
+			b.mu.Lock()
+			b.videoTrack = localTrack
+			b.videoSSRC = uint32(track.SSRC())
+			b.mu.Unlock()
 		} else if track.Kind() == webrtc.RTPCodecTypeAudio {
 			localTrack, err = webrtc.NewTrackLocalStaticRTP(track.Codec().RTPCodecCapability, "audio", "broadcast")
 			if err != nil {
-				s.logger.Error("Failed to create local audio track", zap.Error(err))
+				logger.Error("Failed to create local audio track", zap.Error(err))
 				return
 			}
-			s.broadcaster.audioTrack = localTrack
+			b.mu.Lock()
+			b.audioTrack = localTrack
+			b.mu.Unlock()
 		}
 
 		// Read RTP packets and forward them to all subscribers
-		go s.forwardRTP(track, localTrack)
+		go s.forwardRTP(b, track, localTrack)
+
+		// Restreaming follows the default channel only
+		if track.Kind() == webrtc.RTPCodecTypeVideo && name == defaultChannel {
+			s.channelsMu.RLock()
+			hooks := slices.Clone(s.onStart)
+			s.channelsMu.RUnlock()
+			for _, fn := range hooks {
+				go fn()
+			}
+		}
 	})
 
 	// Set the handler for Peer connection state
 	peerConnection.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
-		s.logger.Info("WHIP connection state changed", zap.String("state", state.String()))
+		logger.Info("WHIP connection state changed", zap.String("state", state.String()))
 
 		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed {
-			if s.broadcaster.peerConnection == peerConnection {
-				s.broadcaster.peerConnection = nil
-				s.broadcaster.videoTrack = nil
-				s.broadcaster.audioTrack = nil
+			b.mu.Lock()
+			if b.peerConnection == peerConnection {
+				b.peerConnection = nil
+				b.videoTrack = nil
+				b.audioTrack = nil
 			}
+			b.mu.Unlock()
+			s.removeChannelIfIdle(name)
 		}
 	})
 
 	// Set the remote SessionDescription
 	if err = peerConnection.SetRemoteDescription(offer); err != nil {
-		s.logger.Error("Failed to set remote description", zap.Error(err))
+		logger.Error("Failed to set remote description", zap.Error(err))
 		http.Error(w, "Failed to set remote description", http.StatusBadRequest)
 		return
 	}
@@ -189,7 +403,7 @@ func (s *Server) handleWHIPOffer(w http.ResponseWriter, r *http.Request) {
 	// Create answer
 	answer, err := peerConnection.CreateAnswer(nil)
 	if err != nil {
-		s.logger.Error("Failed to create answer", zap.Error(err))
+		logger.Error("Failed to create answer", zap.Error(err))
 		http.Error(w, "Failed to create answer", http.StatusInternalServerError)
 		return
 	}
@@ -199,7 +413,7 @@ func (s *Server) handleWHIPOffer(w http.ResponseWriter, r *http.Request) {
 
 	// Sets the LocalDescription, and starts our UDP listeners
 	if err = peerConnection.SetLocalDescription(answer); err != nil {
-		s.logger.Error("Failed to set local description", zap.Error(err))
+		logger.Error("Failed to set local description", zap.Error(err))
 		http.Error(w, "Failed to set local description", http.StatusInternalServerError)
 		return
 	}
@@ -207,7 +421,10 @@ func (s *Server) handleWHIPOffer(w http.ResponseWriter, r *http.Request) {
 	// Block until ICE Gathering is complete, disabling trickle ICE
 	<-gatherComplete
 
-	s.broadcaster.peerConnection = peerConnection
+	b.mu.Lock()
+	b.peerConnection = peerConnection
+	b.mu.Unlock()
+	established = true
 
 	// Send the answer back
 	w.Header().Set("Content-Type", "application/sdp")
@@ -215,14 +432,20 @@ func (s *Server) handleWHIPOffer(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	fmt.Fprint(w, peerConnection.LocalDescription().SDP)
 
-	s.logger.Info("WHIP connection established")
+	logger.Info("WHIP connection established")
 }
 
 // WHEP endpoint - WebRTC-HTTP Egress Protocol for playing from server
 func (s *Server) handleWHEP(w http.ResponseWriter, r *http.Request) {
+	name, ok := channelFromPath(r.URL.Path, "/whep")
+	if !ok {
+		http.Error(w, "Invalid channel name", http.StatusBadRequest)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodPost:
-		s.handleWHEPOffer(w, r)
+		s.handleWHEPOffer(w, r, name)
 	case http.MethodOptions:
 		s.handleWHEPOptions(w, r)
 	default:
@@ -237,19 +460,27 @@ func (s *Server) handleWHEPOptions(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) handleWHEPOffer(w http.ResponseWriter, r *http.Request) {
-	s.broadcaster.mu.RLock()
-	if s.broadcaster.videoTrack == nil && s.broadcaster.audioTrack == nil {
-		s.broadcaster.mu.RUnlock()
+func (s *Server) handleWHEPOffer(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := s.lookupChannel(name)
+	if !ok {
 		http.Error(w, "No active broadcast", http.StatusNotFound)
 		return
 	}
-	s.broadcaster.mu.RUnlock()
+
+	b.mu.RLock()
+	if b.videoTrack == nil && b.audioTrack == nil {
+		b.mu.RUnlock()
+		http.Error(w, "No active broadcast", http.StatusNotFound)
+		return
+	}
+	b.mu.RUnlock()
+
+	logger := s.logger.With(zap.String("channel", name))
 
 	// Read the SDP offer
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		s.logger.Error("Failed to read WHEP request body", zap.Error(err))
+		logger.Error("Failed to read WHEP request body", zap.Error(err))
 		http.Error(w, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
@@ -268,51 +499,54 @@ func (s *Server) handleWHEPOffer(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 	if err != nil {
-		s.logger.Error("Failed to create WHEP peer connection", zap.Error(err))
+		logger.Error("Failed to create WHEP peer connection", zap.Error(err))
 		http.Error(w, "Failed to create peer connection", http.StatusInternalServerError)
 		return
 	}
 
 	// Add tracks to the peer connection
-	s.broadcaster.mu.RLock()
-	if s.broadcaster.videoTrack != nil {
-		if _, err = peerConnection.AddTrack(s.broadcaster.videoTrack); err != nil {
-			s.broadcaster.mu.RUnlock()
-			s.logger.Error("Failed to add video track to WHEP connection", zap.Error(err))
+	b.mu.RLock()
+	if b.videoTrack != nil {
+		if _, err = peerConnection.AddTrack(b.videoTrack); err != nil {
+			b.mu.RUnlock()
+			logger.Error("Failed to add video track to WHEP connection", zap.Error(err))
 			http.Error(w, "Failed to add video track", http.StatusInternalServerError)
 			return
 		}
 	}
 
-	if s.broadcaster.audioTrack != nil {
-		if _, err = peerConnection.AddTrack(s.broadcaster.audioTrack); err != nil {
-			s.broadcaster.mu.RUnlock()
-			s.logger.Error("Failed to add audio track to WHEP connection", zap.Error(err))
+	if b.audioTrack != nil {
+		if _, err = peerConnection.AddTrack(b.audioTrack); err != nil {
+			b.mu.RUnlock()
+			logger.Error("Failed to add audio track to WHEP connection", zap.Error(err))
 			http.Error(w, "Failed to add audio track", http.StatusInternalServerError)
 			return
 		}
 	}
-	s.broadcaster.mu.RUnlock()
+	b.mu.RUnlock()
 
 	// Generate a unique ID for this subscriber
 	subscriberID := fmt.Sprintf("subscriber_%d", time.Now().UnixNano())
 
 	// Set the handler for Peer connection state
 	peerConnection.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
-		s.logger.Info("WHEP connection state changed",
+		logger.Info("WHEP connection state changed",
 			zap.String("subscriber_id", subscriberID),
 			zap.String("state", state.String()))
 
-		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed {
-			s.broadcaster.mu.Lock()
-			delete(s.broadcaster.subscribers, subscriberID)
-			s.broadcaster.mu.Unlock()
+		switch state {
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateDisconnected:
+			s.scheduleSubscriberRemoval(b, name, subscriberID, peerConnection)
+		case webrtc.PeerConnectionStateClosed:
+			s.removeSubscriber(b, name, subscriberID)
+		case webrtc.PeerConnectionStateConnected:
+			s.cancelSubscriberRemoval(b, subscriberID)
 		}
 	})
 
 	// Set the remote SessionDescription
 	if err = peerConnection.SetRemoteDescription(offer); err != nil {
-		s.logger.Error("Failed to set WHEP remote description", zap.Error(err))
+		logger.Error("Failed to set WHEP remote description", zap.Error(err))
 		http.Error(w, "Failed to set remote description", http.StatusBadRequest)
 		return
 	}
@@ -320,7 +554,7 @@ func (s *Server) handleWHEPOffer(w http.ResponseWriter, r *http.Request) {
 	// Create answer
 	answer, err := peerConnection.CreateAnswer(nil)
 	if err != nil {
-		s.logger.Error("Failed to create WHEP answer", zap.Error(err))
+		logger.Error("Failed to create WHEP answer", zap.Error(err))
 		http.Error(w, "Failed to create answer", http.StatusInternalServerError)
 		return
 	}
@@ -329,7 +563,7 @@ func (s *Server) handleWHEPOffer(w http.ResponseWriter, r *http.Request) {
 
 	// Sets the LocalDescription, and starts our UDP listeners
 	if err = peerConnection.SetLocalDescription(answer); err != nil {
-		s.logger.Error("Failed to set WHEP local description", zap.Error(err))
+		logger.Error("Failed to set WHEP local description", zap.Error(err))
 		http.Error(w, "Failed to set local description", http.StatusInternalServerError)
 		return
 	}
@@ -338,20 +572,98 @@ func (s *Server) handleWHEPOffer(w http.ResponseWriter, r *http.Request) {
 	<-gatherComplete
 
 	// Add to subscribers list
-	s.broadcaster.mu.Lock()
-	s.broadcaster.subscribers[subscriberID] = peerConnection
-	s.broadcaster.mu.Unlock()
+	b.mu.Lock()
+	b.subscribers[subscriberID] = peerConnection
+	b.mu.Unlock()
+
+	answerSDP := peerConnection.LocalDescription().SDP
+	if s.maxVideoBitrate > 0 {
+		if hinted, err := withVideoBandwidth(answerSDP, s.maxVideoBitrate); err != nil {
+			logger.Warn("Failed to add bandwidth hint to WHEP answer", zap.Error(err))
+		} else {
+			answerSDP = hinted
+		}
+	}
 
 	// Send the answer back
 	w.Header().Set("Content-Type", "application/sdp")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.WriteHeader(http.StatusCreated)
-	fmt.Fprint(w, peerConnection.LocalDescription().SDP)
+	fmt.Fprint(w, answerSDP)
+
+	logger.Info("WHEP connection established", zap.String("subscriber_id", subscriberID))
+}
+
+// withVideoBandwidth returns the SDP with an application-specific bandwidth
+// line of kbps on each video media section
+func withVideoBandwidth(sdpText string, kbps int) (string, error) {
+	var desc sdp.SessionDescription
+	if err := desc.UnmarshalString(sdpText); err != nil {
+		return "", fmt.Errorf("failed to parse SDP: %w", err)
+	}
+
+	for _, media := range desc.MediaDescriptions {
+		if media.MediaName.Media != "video" {
+			continue
+		}
+		media.Bandwidth = slices.DeleteFunc(media.Bandwidth, func(b sdp.Bandwidth) bool { return b.Type == "AS" })
+		media.Bandwidth = append(media.Bandwidth, sdp.Bandwidth{Type: "AS", Bandwidth: uint64(kbps)})
+	}
 
-	s.logger.Info("WHEP connection established", zap.String("subscriber_id", subscriberID))
+	out, err := desc.Marshal()
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal SDP: %w", err)
+	}
+	return string(out), nil
 }
 
-func (s *Server) forwardRTP(remoteTrack *webrtc.TrackRemote, localTrack *webrtc.TrackLocalStaticRTP) {
+// scheduleSubscriberRemoval removes the subscriber once the grace period
+// passes without it reconnecting
+func (s *Server) scheduleSubscriberRemoval(b *Broadcaster, channel, id string, pc *webrtc.PeerConnection) {
+	if s.subscriberGrace <= 0 {
+		s.removeSubscriber(b, channel, id)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, pending := b.pendingRemoval[id]; pending {
+		return
+	}
+
+	b.pendingRemoval[id] = time.AfterFunc(s.subscriberGrace, func() {
+		s.logger.Info("WHEP subscriber did not recover, removing", zap.String("subscriber_id", id))
+		s.removeSubscriber(b, channel, id)
+		_ = pc.Close()
+	})
+}
+
+// cancelSubscriberRemoval keeps a subscriber that recovered within the grace period
+func (s *Server) cancelSubscriberRemoval(b *Broadcaster, id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if timer, pending := b.pendingRemoval[id]; pending {
+		timer.Stop()
+		delete(b.pendingRemoval, id)
+		s.logger.Info("WHEP subscriber recovered", zap.String("subscriber_id", id))
+	}
+}
+
+func (s *Server) removeSubscriber(b *Broadcaster, channel, id string) {
+	b.mu.Lock()
+	if timer, pending := b.pendingRemoval[id]; pending {
+		timer.Stop()
+		delete(b.pendingRemoval, id)
+	}
+	delete(b.subscribers, id)
+	b.mu.Unlock()
+
+	s.removeChannelIfIdle(channel)
+}
+
+func (s *Server) forwardRTP(b *Broadcaster, remoteTrack *webrtc.TrackRemote, localTrack *webrtc.TrackLocalStaticRTP) {
 	rtpBuf := make([]byte, 1400)
 	for {
 		i, _, err := remoteTrack.Read(rtpBuf)
@@ -360,6 +672,11 @@ func (s *Server) forwardRTP(remoteTrack *webrtc.TrackRemote, localTrack *webrtc.
 			return
 		}
 
+		// Copy to any restream sinks before the local track write, which
+		// may modify the buffer. Both set the payload type their receivers
+		// expect.
+		b.writeSinks(remoteTrack.Kind(), rtpBuf[:i])
+
 		// Write to local track to forward to all subscribers
 		if _, err = localTrack.Write(rtpBuf[:i]); err != nil {
 			s.logger.Debug("Track write error", zap.Error(err))
@@ -368,14 +685,34 @@ func (s *Server) forwardRTP(remoteTrack *webrtc.TrackRemote, localTrack *webrtc.
 	}
 }
 
+// GetStatus reports the default channel at the top level, as served by the
+// bare /whip and /whep endpoints, and every channel under "channels"
 func (s *Server) GetStatus() map[string]interface{} {
-	s.broadcaster.mu.RLock()
-	defer s.broadcaster.mu.RUnlock()
+	s.channelsMu.RLock()
+	defer s.channelsMu.RUnlock()
+
+	channels := make(map[string]interface{}, len(s.channels))
+	for name, b := range s.channels {
+		channels[name] = b.status()
+	}
+
+	status := s.channels[defaultChannel].status()
+	status["channels"] = channels
+	if s.udpPortMin != 0 || s.udpPortMax != 0 {
+		status["udp_port_range"] = fmt.Sprintf("%d-%d", s.udpPortMin, s.udpPortMax)
+	}
+	return status
+}
+
+func (b *Broadcaster) status() map[string]interface{} {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 
 	return map[string]interface{}{
-		"broadcasting":      s.broadcaster.peerConnection != nil,
-		"subscribers_count": len(s.broadcaster.subscribers),
-		"has_video":         s.broadcaster.videoTrack != nil,
-		"has_audio":         s.broadcaster.audioTrack != nil,
+		"broadcasting":                b.peerConnection != nil,
+		"subscribers_count":           len(b.subscribers),
+		"subscribers_pending_removal": len(b.pendingRemoval),
+		"has_video":                   b.videoTrack != nil,
+		"has_audio":                   b.audioTrack != nil,
 	}
 }
@@ -0,0 +1,238 @@
+package webrtc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// newTestSubscriber adds an unconnected peer connection to b as a subscriber
+func newTestSubscriber(t *testing.T, b *Broadcaster, id string) *webrtc.PeerConnection {
+	t.Helper()
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("NewPeerConnection() error = %v", err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+
+	b.mu.Lock()
+	b.subscribers[id] = pc
+	b.mu.Unlock()
+	return pc
+}
+
+// subscriberState returns whether b has the subscriber and whether its
+// removal is pending
+func subscriberState(b *Broadcaster, id string) (subscribed, pending bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, subscribed = b.subscribers[id]
+	_, pending = b.pendingRemoval[id]
+	return subscribed, pending
+}
+
+func TestSubscriberGrace(t *testing.T) {
+	const grace = 20 * time.Millisecond
+	s := newTestServer(t, WithSubscriberGrace(grace))
+	b, _ := s.lookupChannel(defaultChannel)
+	pc := newTestSubscriber(t, b, "viewer")
+
+	// A subscriber that recovers within the grace period is kept
+	s.scheduleSubscriberRemoval(b, defaultChannel, "viewer", pc)
+	if subscribed, pending := subscriberState(b, "viewer"); !subscribed || !pending {
+		t.Fatalf("after a failure subscribed, pending = %v, %v; want true, true", subscribed, pending)
+	}
+	s.cancelSubscriberRemoval(b, "viewer")
+	time.Sleep(2 * grace)
+	if subscribed, pending := subscriberState(b, "viewer"); !subscribed || pending {
+		t.Fatalf("after recovering subscribed, pending = %v, %v; want true, false", subscribed, pending)
+	}
+
+	// Without recovering it is removed once the grace period passes, however
+	// many failures are reported meanwhile
+	s.scheduleSubscriberRemoval(b, defaultChannel, "viewer", pc)
+	s.scheduleSubscriberRemoval(b, defaultChannel, "viewer", pc)
+	deadline := time.Now().Add(time.Second)
+	for {
+		subscribed, pending := subscriberState(b, "viewer")
+		if !subscribed && !pending {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscriber was not removed after the grace period")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSubscriberWithoutGrace(t *testing.T) {
+	s := newTestServer(t, WithSubscriberGrace(0))
+	b, _ := s.lookupChannel(defaultChannel)
+	pc := newTestSubscriber(t, b, "viewer")
+
+	s.scheduleSubscriberRemoval(b, defaultChannel, "viewer", pc)
+	if subscribed, pending := subscriberState(b, "viewer"); subscribed || pending {
+		t.Errorf("subscribed, pending = %v, %v; want the subscriber removed at once", subscribed, pending)
+	}
+}
+
+func TestRemoveSubscriberStopsPendingRemoval(t *testing.T) {
+	s := newTestServer(t, WithSubscriberGrace(time.Hour))
+	b, _ := s.lookupChannel(defaultChannel)
+	pc := newTestSubscriber(t, b, "viewer")
+
+	s.scheduleSubscriberRemoval(b, defaultChannel, "viewer", pc)
+	s.removeSubscriber(b, defaultChannel, "viewer")
+	if subscribed, pending := subscriberState(b, "viewer"); subscribed || pending {
+		t.Errorf("subscribed, pending = %v, %v; want the subscriber and its timer gone", subscribed, pending)
+	}
+	if got := b.status()["subscribers_pending_removal"]; got != 0 {
+		t.Errorf("status subscribers_pending_removal = %v, want 0", got)
+	}
+}
+
+func TestChannelFromPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+		ok       bool
+	}{
+		{path: "/whep", expected: defaultChannel, ok: true},
+		{path: "/whep/", expected: defaultChannel, ok: true},
+		{path: "/whep/studio-2", expected: "studio-2", ok: true},
+		{path: "/whep/studio_b", expected: "studio_b", ok: true},
+		{path: "/whep/a/b", expected: "a/b"},
+		{path: "/whep/bad name", expected: "bad name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			name, ok := channelFromPath(tt.path, "/whep")
+			if name != tt.expected || ok != tt.ok {
+				t.Errorf("channelFromPath(%q) = %q, %v; want %q, %v", tt.path, name, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestChannels(t *testing.T) {
+	s := newTestServer(t)
+
+	studio := s.channel("studio")
+	if again := s.channel("studio"); again != studio {
+		t.Error("channel() returned a new broadcaster for an existing channel")
+	}
+
+	status := s.GetStatus()
+	channels, _ := status["channels"].(map[string]interface{})
+	if _, ok := channels["studio"]; !ok || len(channels) != 2 {
+		t.Errorf("GetStatus() channels = %v, want default and studio", channels)
+	}
+	if status["broadcasting"] != false {
+		t.Errorf("GetStatus() broadcasting = %v, want the default channel's false", status["broadcasting"])
+	}
+
+	// Idle channels are dropped, except the default one
+	s.removeChannelIfIdle("studio")
+	s.removeChannelIfIdle(defaultChannel)
+	if _, ok := s.lookupChannel("studio"); ok {
+		t.Error("idle channel was not removed")
+	}
+	if _, ok := s.lookupChannel(defaultChannel); !ok {
+		t.Error("default channel was removed")
+	}
+
+	// A channel with a subscriber is kept
+	busy := s.channel("busy")
+	newTestSubscriber(t, busy, "viewer")
+	s.removeChannelIfIdle("busy")
+	if _, ok := s.lookupChannel("busy"); !ok {
+		t.Error("channel with a subscriber was removed")
+	}
+}
+
+func TestWHEPChannels(t *testing.T) {
+	s := newTestServer(t)
+	mux := http.NewServeMux()
+	s.SetupRoutes(mux)
+
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{path: "/whep", expected: http.StatusNotFound},
+		{path: "/whep/unknown", expected: http.StatusNotFound},
+		{path: "/whep/bad%20name", expected: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader("v=0")))
+			if rec.Code != tt.expected {
+				t.Errorf("POST %s = %d, want %d", tt.path, rec.Code, tt.expected)
+			}
+		})
+	}
+	if _, ok := s.lookupChannel("unknown"); ok {
+		t.Error("WHEP request created a channel, want only WHIP to create them")
+	}
+}
+
+func TestWHIPFailedOfferChannel(t *testing.T) {
+	s := newTestServer(t)
+	mux := http.NewServeMux()
+	s.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/whip/phantom", strings.NewReader("not sdp")))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("POST /whip/phantom with invalid SDP = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if _, ok := s.lookupChannel("phantom"); ok {
+		t.Error("failed WHIP offer left its channel behind")
+	}
+	if channels, _ := s.GetStatus()["channels"].(map[string]interface{}); len(channels) != 1 {
+		t.Errorf("GetStatus() channels = %v, want only the default", channels)
+	}
+}
+
+const testAnswerSDP = "v=0\r\n" +
+	"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"b=AS:500\r\n" +
+	"a=rtpmap:96 H264/90000\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n"
+
+func TestWithVideoBandwidth(t *testing.T) {
+	got, err := withVideoBandwidth(testAnswerSDP, 2500)
+	if err != nil {
+		t.Fatalf("withVideoBandwidth() error = %v", err)
+	}
+
+	video, audio, ok := strings.Cut(got, "m=audio")
+	if !ok {
+		t.Fatalf("withVideoBandwidth() = %q, lost the audio section", got)
+	}
+	// The hint replaces any bandwidth the video section already had
+	if !strings.Contains(video, "b=AS:2500") || strings.Contains(video, "b=AS:500\r\n") {
+		t.Errorf("video section = %q, want only b=AS:2500", video)
+	}
+	if strings.Contains(audio, "b=AS") {
+		t.Errorf("audio section = %q, want no bandwidth hint", audio)
+	}
+
+	if _, err := withVideoBandwidth("not sdp", 2500); err == nil {
+		t.Error("withVideoBandwidth() with invalid SDP = nil, want error")
+	}
+}
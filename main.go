@@ -2,17 +2,24 @@ package main
 
 import (
 	"context"
+	"embed"
 	"errors"
 	"flag"
+	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/jbpratt/streammanager/internal/api"
+	"github.com/jbpratt/streammanager/internal/logbuffer"
+	"github.com/jbpratt/streammanager/internal/streammanager"
 	"github.com/jbpratt/streammanager/internal/webrtc"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -22,12 +29,65 @@ import (
 // TODO: improve the fps in the progress to not estimate total frames instead using ffprobe to calculate
 //       ffprobe -v quiet -select_streams v:0 -show_entries stream=nb_frames,duration,r_frame_rate -of json
 
+//go:embed www
+var wwwFS embed.FS
+
+// staticHandler serves the web UI from the embedded assets, or from dir on
+// disk when set so assets can be live-edited during development.
+func staticHandler(dir string) (http.Handler, error) {
+	if dir != "" {
+		return http.FileServer(http.Dir(dir)), nil
+	}
+
+	sub, err := fs.Sub(wwwFS, "www")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedded web assets: %w", err)
+	}
+	return http.FileServer(http.FS(sub)), nil
+}
+
+// parsePortRange parses a port range written as min-max
+func parsePortRange(r string) (uint16, uint16, error) {
+	lo, hi, ok := strings.Cut(r, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("port range %q must be written as min-max", r)
+	}
+	portMin, err := strconv.ParseUint(lo, 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid minimum port: %w", err)
+	}
+	portMax, err := strconv.ParseUint(hi, 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid maximum port: %w", err)
+	}
+	if portMin == 0 || portMax == 0 {
+		return 0, 0, fmt.Errorf("port range %q must not include port 0", r)
+	}
+	if portMin > portMax {
+		return 0, 0, fmt.Errorf("port range %q has a minimum above its maximum", r)
+	}
+	return uint16(portMin), uint16(portMax), nil
+}
+
 func main() {
 	addr := flag.String("http-addr", ":8080", "server address")
 	rtmpAddr := flag.String("rtmp-addr", ":1935", "RTMP server address")
 	logLevel := flag.String("log-level", "info", "Log level (debug, info)")
 	fileDir := flag.String("file-dir", ".", "Directory to serve files from")
+	sourceDirs := flag.String("allowed-source-dirs", "", "Comma-separated directories enqueued files must be within, in addition to --file-dir (default: any path)")
 	fifoPath := flag.String("fifo-path", "/tmp/streampipe.fifo", "Path to the FIFO file")
+	integrityCheck := flag.String("integrity-check", "off", "Check enqueued files for being incomplete (off, size, decode)")
+	maxFilters := flag.Int("max-filters", 12, "Maximum number of video and audio filters preprocessing may apply to one entry")
+	probeTimeout := flag.Duration("probe-timeout", 30*time.Second, "Maximum time probing a file may take, retries included")
+	startGrace := flag.Duration("start-grace", 0, "Time after a stopped stream finishes tearing down before a new start is accepted")
+	probeRetries := flag.Int("probe-retries", 2, "Times a failed ffprobe run is retried, with backoff, before the file is rejected")
+	maxEncodes := flag.Int("max-encodes", runtime.NumCPU(), "Maximum number of concurrent preprocessing ffmpeg processes, which all re-encode")
+	staticDir := flag.String("static-dir", "", "Serve web UI assets from this directory instead of the embedded copy")
+	webrtcMaxBitrate := flag.Int("webrtc-max-bitrate", 0, "Video bitrate hint in kbps advertised to WebRTC viewers, 0 for none")
+	webrtcInterfaces := flag.String("webrtc-interfaces", "", "Comma-separated network interfaces to gather WebRTC candidates on (default: all)")
+	webrtcPublicIPs := flag.String("webrtc-public-ips", "", "Comma-separated public IPs to advertise in place of host addresses, for 1:1 NAT")
+	webrtcUDPPorts := flag.String("webrtc-udp-ports", "", "UDP port range for WebRTC as min-max, e.g. 50000-50100 (default: any)")
+	adminToken := flag.String("admin-token", os.Getenv("STREAMMANAGER_ADMIN_TOKEN"), "Bearer token for admin endpoints such as /shutdown, disabled when empty (env STREAMMANAGER_ADMIN_TOKEN)")
 	flag.Parse()
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -41,7 +101,15 @@ func main() {
 	// Create atomic level for runtime changes
 	atomicLevel := zap.NewAtomicLevelAt(level)
 
-	logger, err := zap.NewDevelopment(zap.IncreaseLevel(atomicLevel))
+	// Keep recent entries in memory so they can be read over HTTP
+	logBuffer := logbuffer.New(1000)
+
+	logger, err := zap.NewDevelopment(
+		zap.IncreaseLevel(atomicLevel),
+		zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, logBuffer.Core(atomicLevel))
+		}),
+	)
 	if err != nil {
 		panic(err)
 	}
@@ -49,17 +117,65 @@ func main() {
 		_ = logger.Sync() // Safe to ignore error in defer during shutdown
 	}()
 
+	streammanager.SetMaxConcurrentEncodes(*maxEncodes)
+	streammanager.SetProbeTimeout(*probeTimeout)
+	streammanager.SetProbeRetries(*probeRetries)
+
+	if version, err := streammanager.DetectFFmpegVersion(ctx); err != nil {
+		logger.Warn("Failed to detect ffmpeg version", zap.Error(err))
+	} else {
+		streammanager.SetFFmpegVersion(version)
+		if version.Supported() {
+			logger.Info("Detected ffmpeg", zap.String("version", version.Raw))
+		} else {
+			logger.Warn("ffmpeg is older than the minimum supported version, using legacy flags",
+				zap.String("version", version.Raw),
+				zap.String("minimum", streammanager.MinFFmpegVersion().Raw))
+		}
+	}
+
 	apiServer, err := api.New(logger, *rtmpAddr, &atomicLevel, *fifoPath)
 	if err != nil {
 		logger.Fatal("Failed to create API server", zap.Error(err))
 	}
 
+	apiServer.SetLogBuffer(logBuffer)
+	apiServer.StreamManager().SetMaxFilters(*maxFilters)
+	apiServer.StreamManager().SetStartGracePeriod(*startGrace)
+	apiServer.SetAdminToken(*adminToken)
+	apiServer.SetShutdownFunc(stop)
+
 	// Set file directory for file serving
 	if err := apiServer.SetFileDirectory(*fileDir); err != nil {
 		logger.Fatal("Failed to set file directory", zap.Error(err))
 	}
 
-	webrtcServer, err := webrtc.NewServer(logger)
+	if *sourceDirs != "" {
+		if err := apiServer.SetAllowedSourceDirs(strings.Split(*sourceDirs, ",")); err != nil {
+			logger.Fatal("Failed to set allowed source directories", zap.Error(err))
+		}
+	}
+
+	if err := apiServer.SetIntegrityCheck(*integrityCheck); err != nil {
+		logger.Fatal("Failed to set integrity check", zap.Error(err))
+	}
+
+	webrtcOpts := []webrtc.Option{webrtc.WithMaxVideoBitrate(*webrtcMaxBitrate)}
+	if *webrtcInterfaces != "" {
+		webrtcOpts = append(webrtcOpts, webrtc.WithInterfaces(strings.Split(*webrtcInterfaces, ",")))
+	}
+	if *webrtcPublicIPs != "" {
+		webrtcOpts = append(webrtcOpts, webrtc.WithNAT1To1IPs(strings.Split(*webrtcPublicIPs, ",")))
+	}
+	if *webrtcUDPPorts != "" {
+		portMin, portMax, err := parsePortRange(*webrtcUDPPorts)
+		if err != nil {
+			logger.Fatal("Invalid WebRTC UDP port range", zap.Error(err))
+		}
+		webrtcOpts = append(webrtcOpts, webrtc.WithUDPPortRange(portMin, portMax))
+	}
+
+	webrtcServer, err := webrtc.NewServer(logger, webrtcOpts...)
 	if err != nil {
 		logger.Fatal("Failed to create WebRTC server", zap.Error(err))
 	}
@@ -69,7 +185,12 @@ func main() {
 
 	mux := http.NewServeMux()
 
-	mux.Handle("/", http.FileServer(http.Dir("www")))
+	static, err := staticHandler(*staticDir)
+	if err != nil {
+		logger.Fatal("Failed to create static file handler", zap.Error(err))
+	}
+
+	mux.Handle("/", static)
 	apiServer.SetupRoutes(mux)
 	webrtcServer.SetupRoutes(mux)
 
@@ -77,6 +198,7 @@ func main() {
 		Addr:    *addr,
 		Handler: mux,
 	}
+	srvr.RegisterOnShutdown(apiServer.CloseStreams)
 
 	errC := make(chan error, 1)
 	go func() {
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       string
+		wantMin uint16
+		wantMax uint16
+		wantErr bool
+	}{
+		{name: "range", r: "50000-50100", wantMin: 50000, wantMax: 50100},
+		{name: "single port", r: "50000-50000", wantMin: 50000, wantMax: 50000},
+		{name: "missing separator", r: "50000", wantErr: true},
+		{name: "non-numeric minimum", r: "low-50100", wantErr: true},
+		{name: "non-numeric maximum", r: "50000-high", wantErr: true},
+		{name: "maximum above 65535", r: "50000-70000", wantErr: true},
+		{name: "inverted", r: "50100-50000", wantErr: true},
+		{name: "port 0", r: "0-50100", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			portMin, portMax, err := parsePortRange(tt.r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePortRange(%q) error = %v, wantErr %v", tt.r, err, tt.wantErr)
+			}
+			if portMin != tt.wantMin || portMax != tt.wantMax {
+				t.Errorf("parsePortRange(%q) = %d, %d; want %d, %d", tt.r, portMin, portMax, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
@@ -158,6 +158,15 @@ func TestEndToEnd(t *testing.T) {
 			t.Fatalf("Expected status 200, got %d", resp.StatusCode)
 		}
 
+		var result map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode start response: %v", err)
+		}
+
+		if result["status"] != "ok" {
+			t.Fatalf("Expected status ok in start response, got %v", result)
+		}
+
 		// Give streaming time to start
 		time.Sleep(500 * time.Millisecond)
 	})
@@ -942,6 +951,64 @@ a=rtpmap:96 H264/90000`
 			logger.Info("Numeric timestamp enqueue test passed", zap.String("id", result["id"]))
 		})
 
+		// Test trimming a clip to a start and end timestamp
+		t.Run("start_and_end_timestamp", func(t *testing.T) {
+			reqBody := map[string]any{
+				"file": testFile,
+				"overlay": map[string]any{
+					"showFilename": false,
+					"position":     "bottom-right",
+					"fontSize":     24,
+				},
+				"startTimestamp": "0:00:02",
+				"endTimestamp":   "0:00:05", // Plays 3 seconds
+			}
+
+			reqJSON, err := json.Marshal(reqBody)
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+
+			resp, err := http.Post("http://localhost:8081/enqueue", "application/json", bytes.NewReader(reqJSON))
+			if err != nil {
+				t.Fatalf("Failed to enqueue file with end timestamp: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("Expected status 200 for start and end timestamps, got %d: %s", resp.StatusCode, string(body))
+			}
+
+			var result map[string]string
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			queueResp, err := http.Get("http://localhost:8081/queue")
+			if err != nil {
+				t.Fatalf("Failed to get queue: %v", err)
+			}
+			defer queueResp.Body.Close()
+
+			var queue struct {
+				Queue []struct {
+					ID           string `json:"id"`
+					EndTimestamp string `json:"endTimestamp"`
+				} `json:"queue"`
+			}
+			if err := json.NewDecoder(queueResp.Body).Decode(&queue); err != nil {
+				t.Fatalf("Failed to decode queue: %v", err)
+			}
+			for _, e := range queue.Queue {
+				if e.ID == result["id"] && e.EndTimestamp != "0:00:05" {
+					t.Errorf("Queued entry endTimestamp = %q, want 0:00:05", e.EndTimestamp)
+				}
+			}
+
+			logger.Info("Start and end timestamp enqueue test passed", zap.String("id", result["id"]))
+		})
+
 		// Test empty timestamp (should work normally)
 		t.Run("empty_timestamp", func(t *testing.T) {
 			reqBody := map[string]any{
@@ -231,6 +231,7 @@ class StreamManager {
       document.getElementById("keyframeInterval").value,
       document.getElementById("ffmpegLogLevel").value,
     );
+    config.streamKey = document.getElementById("streamKey").value.trim();
 
     try {
       const result = await this.streamController.start(config);
@@ -23,7 +23,11 @@ export default class ProgressManager {
       if (response.ok) {
         const data = await response.json();
         if (data.hasProgress && data.progress) {
-          this.onProgressUpdate?.(data.progress);
+          this.onProgressUpdate?.({
+            ...data.progress,
+            speedFactor: data.speedFactor,
+            keepingUp: data.keepingUp,
+          });
           return { success: true, data: data.progress };
         } else {
           this.onNoProgress?.();
@@ -34,7 +34,8 @@ export default class QueueManager {
       throw new Error(await response.text());
     }
 
-    return await response.text();
+    const data = await response.json();
+    return data.message;
   }
 
   async getQueue() {
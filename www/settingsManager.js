@@ -4,6 +4,7 @@ class SettingsManager {
     this.settingIds = [
       // Stream Configuration
       "destInput",
+      "streamKey",
       "username",
       "password",
       "maxBitrate",
@@ -62,8 +62,12 @@ export default class StatusManager {
     document.getElementById("progressBitrate").textContent = progress.bitrate ||
       "-";
     document.getElementById("progressTime").textContent = progress.time || "-";
-    document.getElementById("progressSpeed").textContent = progress.speed ||
-      "-";
+    const speedEl = document.getElementById("progressSpeed");
+    const fallingBehind = progress.speedFactor > 0 && !progress.keepingUp;
+    speedEl.textContent = (progress.speed || "-") +
+      (fallingBehind ? " (falling behind)" : "");
+    speedEl.classList.toggle("text-red-600", fallingBehind);
+    speedEl.classList.toggle("dark:text-red-400", fallingBehind);
 
     // Update timestamp
     if (progress.timestamp) {
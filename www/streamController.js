@@ -12,7 +12,7 @@ export default class StreamController {
       body: JSON.stringify(config),
     });
 
-    const text = await response.text();
+    const text = await this.readMessage(response);
 
     if (response.ok) {
       this.isRunning = true;
@@ -27,7 +27,7 @@ export default class StreamController {
 
   async stop() {
     const response = await fetch("/stop", { method: "POST" });
-    const text = await response.text();
+    const text = await this.readMessage(response);
 
     if (response.ok) {
       this.isRunning = false;
@@ -42,7 +42,7 @@ export default class StreamController {
 
   async skip() {
     const response = await fetch("/skip", { method: "POST" });
-    const text = await response.text();
+    const text = await this.readMessage(response);
 
     return {
       success: response.ok,
@@ -50,6 +50,14 @@ export default class StreamController {
     };
   }
 
+  async readMessage(response) {
+    if (!response.ok) {
+      return await response.text();
+    }
+    const data = await response.json();
+    return data.warning ? `${data.message} (${data.warning})` : data.message;
+  }
+
   setRunning(running) {
     this.isRunning = running;
   }